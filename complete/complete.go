@@ -7,6 +7,7 @@ import (
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	rw "github.com/mattn/go-runewidth"
@@ -139,6 +140,19 @@ type Model struct {
 
 	values Values
 
+	// loading is true while the values are being computed. A spinner
+	// is displayed in the meantime.
+	loading bool
+	spinner spinner.Model
+
+	// renderer is the lipgloss renderer configured with SetRenderer,
+	// if any.
+	renderer *lipgloss.Renderer
+
+	// noFiltering disables the filter of the lists, see
+	// SetFilteringEnabled.
+	noFiltering bool
+
 	selectedList int
 	listItems    [][]list.Item
 	valueLists   []*list.Model
@@ -153,6 +167,7 @@ func (m *Model) Debug() string {
 		fmt.Fprintf(&buf, "selected item: %v\n", m.valueLists[m.selectedList].SelectedItem())
 	}
 	fmt.Fprintf(&buf, "accepted: %+v / err %v\n", m.AcceptedValue, m.Err)
+	fmt.Fprintf(&buf, "loading: %v\n", m.loading)
 	return buf.String()
 }
 
@@ -163,10 +178,35 @@ func New() Model {
 		KeyMap:  DefaultKeyMap,
 		Styles:  DefaultStyles,
 		focused: true,
+		spinner: spinner.New(spinner.WithSpinner(spinner.Line)),
 	}
 }
 
-type candidateItem struct{ Entry }
+// StartLoading displays a spinner instead of the completions, until
+// StopLoading is called. The returned command animates the spinner.
+func (m *Model) StartLoading() tea.Cmd {
+	m.loading = true
+	m.spinner.Style = m.Styles.Spinner
+	return m.spinner.Tick
+}
+
+// StopLoading removes the spinner displayed by StartLoading.
+func (m *Model) StopLoading() {
+	m.loading = false
+}
+
+// Loading returns true while the spinner is displayed.
+func (m *Model) Loading() bool {
+	return m.loading
+}
+
+type candidateItem struct {
+	Entry
+
+	// matches is the set of characters matched by the pattern set
+	// with SetPattern, if any.
+	matches []int
+}
 
 var _ list.Item = candidateItem{}
 
@@ -183,7 +223,7 @@ func convertToItems(values Values, catIdx int) (res []list.Item, maxWidth int) {
 		it := values.Entry(catIdx, i)
 		// TODO(knz): Support multi-line items.
 		maxWidth = max(maxWidth, rw.StringWidth(it.Title()))
-		res[i] = candidateItem{it}
+		res[i] = candidateItem{Entry: it}
 	}
 	return res, maxWidth
 }
@@ -208,11 +248,20 @@ func (r *renderer) Render(w io.Writer, m list.Model, index int, item list.Item)
 		s += strings.Repeat(" ", r.width-iw)
 	}
 	st := &r.m.Styles
-	fn := st.Item.Render
+	style := st.Item
 	if r.m.selectedList == r.listIdx && index == m.Index() {
-		fn = st.SelectedItem.Render
+		style = st.SelectedItem
+	}
+	if len(i.matches) > 0 {
+		// Highlight the matched characters. The padding is rendered
+		// separately so that the highlight only applies to the title.
+		inner := style.UnsetPadding()
+		s = lipgloss.StyleRunes(s, i.matches, st.DefaultFilterCharacterMatch.Inherit(inner), inner)
+		s = lipgloss.NewStyle().Padding(style.GetPadding()).Render(s)
+	} else {
+		s = style.Render(s)
 	}
-	fmt.Fprint(w, fn(s))
+	fmt.Fprint(w, s)
 }
 
 // Height is part of the list.ItemDelegate interface.
@@ -241,7 +290,17 @@ func (m *Model) SetHeight(height int) {
 		l.SetHeight(m.height - 1)
 		// Force recomputing the keybindings, which
 		// is dependent on the page size.
-		l.SetFilteringEnabled(true)
+		l.SetFilteringEnabled(!m.noFiltering)
+	}
+}
+
+// SetFilteringEnabled enables or disables the filter prompt of the
+// lists, for example when the entries are filtered with SetPattern
+// instead. Filtering is enabled by default.
+func (m *Model) SetFilteringEnabled(enabled bool) {
+	m.noFiltering = !enabled
+	for _, l := range m.valueLists {
+		l.SetFilteringEnabled(enabled)
 	}
 }
 
@@ -295,6 +354,7 @@ func (m *Model) SetValues(values Values) {
 		l.DisableQuitKeybindings()
 		l.SetShowHelp(false)
 		l.SetShowStatusBar(false)
+		m.setListRenderer(&l)
 		m.valueLists[i] = &l
 	}
 
@@ -311,6 +371,19 @@ func (m *Model) SetValues(values Values) {
 	}
 }
 
+// SelectedEntry returns the entry currently selected, or nil if
+// there is none.
+func (m *Model) SelectedEntry() Entry {
+	if len(m.valueLists) == 0 {
+		return nil
+	}
+	v, ok := m.valueLists[m.selectedList].SelectedItem().(candidateItem)
+	if !ok {
+		return nil
+	}
+	return v.Entry
+}
+
 // MatchesKeys returns true when the completion
 // editor can use the given key message.
 func (m *Model) MatchesKey(msg tea.KeyMsg) bool {
@@ -324,16 +397,18 @@ func (m *Model) MatchesKey(msg tea.KeyMsg) bool {
 		m.KeyMap.CursorDown,
 		m.KeyMap.GoToStart,
 		m.KeyMap.GoToEnd,
-		m.KeyMap.Filter,
-		m.KeyMap.ClearFilter,
-		m.KeyMap.CancelWhileFiltering,
-		m.KeyMap.AcceptWhileFiltering,
 		m.KeyMap.PrevCompletions,
 		m.KeyMap.NextCompletions,
 		m.KeyMap.NextPage,
 		m.KeyMap.PrevPage,
 		m.KeyMap.Abort):
 		return true
+	case !m.noFiltering && key.Matches(msg,
+		m.KeyMap.Filter,
+		m.KeyMap.ClearFilter,
+		m.KeyMap.CancelWhileFiltering,
+		m.KeyMap.AcceptWhileFiltering):
+		return true
 	case !curList.SettingFilter() &&
 		key.Matches(msg, m.KeyMap.AcceptCompletion):
 		return true
@@ -391,6 +466,15 @@ func (m *Model) Init() tea.Cmd {
 
 // Update implements the tea.Model interface.
 func (m *Model) Update(imsg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := imsg.(spinner.TickMsg); ok {
+		if !m.loading {
+			// Stop the animation.
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
 	if len(m.valueLists) == 0 {
 		m.Err = io.EOF
 		return m, nil
@@ -423,7 +507,12 @@ func (m *Model) Update(imsg tea.Msg) (tea.Model, tea.Cmd) {
 					imsg = nil
 				}
 			case key.Matches(msg, m.KeyMap.AcceptCompletion):
-				v := curList.SelectedItem().(candidateItem)
+				v, ok := curList.SelectedItem().(candidateItem)
+				if !ok {
+					// No entry to accept.
+					imsg = nil
+					break
+				}
 				m.AcceptedValue = v.Entry
 				m.Err = io.EOF
 				imsg = nil
@@ -437,13 +526,16 @@ func (m *Model) Update(imsg tea.Msg) (tea.Model, tea.Cmd) {
 	// By default, the list blocks the enter key when the
 	// filtering prompt is open but there is no filter entered.
 	// We don't like this - enter should just accept the current item.
-	newModel.KeyMap.AcceptWhileFiltering.SetEnabled(true)
+	newModel.KeyMap.AcceptWhileFiltering.SetEnabled(!m.noFiltering)
 	m.valueLists[m.selectedList] = &newModel
 	return m, cmd
 }
 
 // View implements the tea.Model interface.
 func (m *Model) View() string {
+	if m.loading {
+		return m.spinner.View() + " " + m.Styles.PlaceholderDescription.Render("fetching completions...")
+	}
 	contents := make([]string, len(m.valueLists))
 	for i, l := range m.valueLists {
 		contents[i] = l.View()
@@ -0,0 +1,148 @@
+package complete
+
+import (
+	"math"
+	"sort"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// FuzzyMatch reports whether all the characters in pattern appear in
+// s in the same order, ignoring case. The returned score is higher
+// for better matches: characters matched consecutively or at the
+// start of a word count more than characters scattered throughout s.
+// matches contains the (rune) positions of the matched characters in
+// s, for the highest scoring match.
+//
+// An empty pattern matches everything with a score of zero.
+func FuzzyMatch(pattern, s string) (score int, matches []int, ok bool) {
+	p, r := []rune(pattern), []rune(s)
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+	if len(p) > len(r) {
+		return 0, nil, false
+	}
+
+	// best[i][j] is the best score for matching p[:i+1] with p[i] at
+	// position j in r, or noMatch. from[i][j] is the position of p[i-1]
+	// in that match.
+	const noMatch = math.MinInt / 2
+	best := make([][]int, len(p))
+	from := make([][]int, len(p))
+	for i := range p {
+		best[i] = make([]int, len(r))
+		from[i] = make([]int, len(r))
+		// bestGap is the best value of best[i-1][k]+k for k < j-1, used
+		// to penalize the gap between the previous match and j.
+		bestGap, bestGapPos := noMatch, -1
+		for j := range r {
+			best[i][j] = noMatch
+			if i > 0 && j >= 2 && best[i-1][j-2] != noMatch && best[i-1][j-2]+j-2 > bestGap {
+				bestGap, bestGapPos = best[i-1][j-2]+j-2, j-2
+			}
+			if unicode.ToLower(r[j]) != unicode.ToLower(p[i]) {
+				continue
+			}
+			charScore := 16
+			if r[j] == p[i] {
+				// Exact case.
+				charScore++
+			}
+			wordStart := j == 0 || isWordStart(r[j-1], r[j])
+			if wordStart {
+				charScore += 8
+			}
+			if i == 0 {
+				// Penalize the characters skipped before the first match, a
+				// little.
+				best[i][j] = charScore - min(j, 3)
+				continue
+			}
+			if j >= 1 && best[i-1][j-1] != noMatch {
+				// Consecutive match.
+				consecutive := best[i-1][j-1] + charScore
+				if !wordStart {
+					consecutive += 12
+				}
+				best[i][j], from[i][j] = consecutive, j-1
+			}
+			if bestGap != noMatch {
+				// Penalize the gap since the previous match.
+				if gap := bestGap - j + 1 + charScore; gap > best[i][j] {
+					best[i][j], from[i][j] = gap, bestGapPos
+				}
+			}
+		}
+	}
+
+	last := len(p) - 1
+	end := -1
+	for j := range r {
+		if best[last][j] != noMatch && (end == -1 || best[last][j] > best[last][end]) {
+			end = j
+		}
+	}
+	if end == -1 {
+		return 0, nil, false
+	}
+	score = best[last][end]
+	matches = make([]int, len(p))
+	for i := last; i >= 0; i-- {
+		matches[i] = end
+		end = from[i][end]
+	}
+	return score, matches, true
+}
+
+// isWordStart returns true if cur starts a word after prev.
+func isWordStart(prev, cur rune) bool {
+	isAlnum := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	return (!isAlnum(prev) && isAlnum(cur)) ||
+		(unicode.IsLower(prev) && unicode.IsUpper(cur))
+}
+
+// SetPattern filters the values using FuzzyMatch, keeping only the
+// entries that match the pattern. Each category is sorted by
+// decreasing score and the matched characters are highlighted.
+// An empty pattern restores all the entries in their original order.
+func (m *Model) SetPattern(pattern string) {
+	type scored struct {
+		item  candidateItem
+		score int
+	}
+	firstNonEmpty := -1
+	for i, l := range m.valueLists {
+		var res []scored
+		for _, it := range m.listItems[i] {
+			c := it.(candidateItem)
+			score, matches, ok := FuzzyMatch(pattern, c.Title())
+			if !ok {
+				continue
+			}
+			c.matches = matches
+			res = append(res, scored{c, score})
+		}
+		sort.SliceStable(res, func(i, j int) bool { return res[i].score > res[j].score })
+		items := make([]list.Item, len(res))
+		for j, s := range res {
+			items[j] = s.item
+		}
+		_ = l.SetItems(items)
+		l.Select(0)
+		if firstNonEmpty == -1 && len(items) > 0 {
+			firstNonEmpty = i
+		}
+	}
+	if firstNonEmpty >= 0 && len(m.valueLists[m.selectedList].Items()) == 0 {
+		// The current category has no match left. Move the selection to
+		// one that does.
+		wasFocused := m.focused
+		m.Blur()
+		m.selectedList = firstNonEmpty
+		if wasFocused {
+			m.Focus()
+		}
+	}
+}
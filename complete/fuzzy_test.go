@@ -0,0 +1,46 @@
+package complete
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	td := []struct {
+		pattern, s string
+		exp        string
+	}{
+		{"", "hello", "0 []"},
+		{"hel", "hello", "83 [0 1 2]"},
+		{"HEL", "hello", "80 [0 1 2]"},
+		// Consecutive matches are preferred.
+		{"hlo", "hello", "69 [0 3 4]"},
+		{"lo", "hello", "43 [3 4]"},
+		{"ht", "hello_there", "45 [0 6]"},
+		{"ht", "helloThere", "45 [0 5]"},
+		{"ho", "hello", "39 [0 4]"},
+		{"x", "hello", "no match"},
+		{"oh", "hello", "no match"},
+		{"hellos", "hello", "no match"},
+	}
+
+	for _, tc := range td {
+		score, matches, ok := FuzzyMatch(tc.pattern, tc.s)
+		actual := "no match"
+		if ok {
+			actual = fmt.Sprintf("%d %v", score, matches)
+		}
+		if actual != tc.exp {
+			t.Errorf("%q in %q: expected %s, got %s", tc.pattern, tc.s, tc.exp, actual)
+		}
+	}
+
+	// Prefix and word start matches rank higher than scattered matches.
+	s1, _, _ := FuzzyMatch("jo", "John")
+	s2, _, _ := FuzzyMatch("jo", "Joseph")
+	s3, _, _ := FuzzyMatch("jo", "Major")
+	s4, _, _ := FuzzyMatch("jo", "Jerome")
+	if !(s1 == s2 && s2 > s3 && s3 > s4) {
+		t.Errorf("unexpected ranking: %d %d %d %d", s1, s2, s3, s4)
+	}
+}
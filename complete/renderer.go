@@ -0,0 +1,73 @@
+package complete
+
+import (
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Renderer returns a copy of the styles where all the component
+// styles are bound to the given lipgloss renderer.
+func (s Styles) Renderer(r *lipgloss.Renderer) Styles {
+	s.FocusedTitleBar = s.FocusedTitleBar.Renderer(r)
+	s.FocusedTitle = s.FocusedTitle.Renderer(r)
+	s.BlurredTitleBar = s.BlurredTitleBar.Renderer(r)
+	s.BlurredTitle = s.BlurredTitle.Renderer(r)
+	s.Item = s.Item.Renderer(r)
+	s.SelectedItem = s.SelectedItem.Renderer(r)
+	s.Spinner = s.Spinner.Renderer(r)
+	s.FilterPrompt = s.FilterPrompt.Renderer(r)
+	s.FilterCursor = s.FilterCursor.Renderer(r)
+	s.PaginationStyle = s.PaginationStyle.Renderer(r)
+	s.DefaultFilterCharacterMatch = s.DefaultFilterCharacterMatch.Renderer(r)
+	s.ActivePaginationDot = s.ActivePaginationDot.Renderer(r)
+	s.InactivePaginationDot = s.InactivePaginationDot.Renderer(r)
+	s.ArabicPagination = s.ArabicPagination.Renderer(r)
+	s.DividerDot = s.DividerDot.Renderer(r)
+	s.PlaceholderDescription = s.PlaceholderDescription.Renderer(r)
+	s.Description = s.Description.Renderer(r)
+	return s
+}
+
+// SetRenderer configures the widget to display using the given
+// lipgloss renderer instead of the default one. The styles
+// configured at the time of the call are bound to the renderer.
+func (m *Model) SetRenderer(r *lipgloss.Renderer) {
+	m.renderer = r
+	m.Styles = m.Styles.Renderer(r)
+	m.spinner.Style = m.spinner.Style.Renderer(r)
+	for _, l := range m.valueLists {
+		m.setListRenderer(l)
+	}
+}
+
+// setListRenderer binds the styles of a list to the renderer
+// configured with SetRenderer, if any.
+func (m *Model) setListRenderer(l *list.Model) {
+	r := m.renderer
+	if r == nil {
+		return
+	}
+	s := &l.Styles
+	s.TitleBar = s.TitleBar.Renderer(r)
+	s.Title = s.Title.Renderer(r)
+	s.Spinner = s.Spinner.Renderer(r)
+	s.FilterPrompt = s.FilterPrompt.Renderer(r)
+	s.FilterCursor = s.FilterCursor.Renderer(r)
+	s.DefaultFilterCharacterMatch = s.DefaultFilterCharacterMatch.Renderer(r)
+	s.StatusBar = s.StatusBar.Renderer(r)
+	s.StatusEmpty = s.StatusEmpty.Renderer(r)
+	s.StatusBarActiveFilter = s.StatusBarActiveFilter.Renderer(r)
+	s.StatusBarFilterCount = s.StatusBarFilterCount.Renderer(r)
+	s.NoItems = s.NoItems.Renderer(r)
+	s.PaginationStyle = s.PaginationStyle.Renderer(r)
+	s.HelpStyle = s.HelpStyle.Renderer(r)
+	s.ActivePaginationDot = s.ActivePaginationDot.Renderer(r)
+	s.InactivePaginationDot = s.InactivePaginationDot.Renderer(r)
+	s.ArabicPagination = s.ArabicPagination.Renderer(r)
+	s.DividerDot = s.DividerDot.Renderer(r)
+	// The pagination dots are rendered once when the list is created.
+	l.Paginator.ActiveDot = s.ActivePaginationDot.String()
+	l.Paginator.InactiveDot = s.InactivePaginationDot.String()
+	l.FilterInput.PromptStyle = s.FilterPrompt
+	l.FilterInput.Cursor.Style = s.FilterCursor
+}
@@ -0,0 +1,82 @@
+package computil
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Words splits the input into words, as found by FindWord: the
+// sequences of characters separated by whitespace. It is meant for
+// use as a tokenizer for the Model.Tokenizer field.
+func Words(s string) []string {
+	var words []string
+	for _, line := range strings.Split(s, "\n") {
+		v := [][]rune{[]rune(line)}
+		for col := 0; col < len(v[0]); {
+			if unicode.IsSpace(v[0][col]) {
+				col++
+				continue
+			}
+			word, _, wordEnd := FindWord(v, 0, col)
+			words = append(words, word)
+			col = wordEnd
+		}
+	}
+	return words
+}
+
+// ShellWords is like Words, but it respects shell quoting: the
+// whitespace within single or double quotes, or preceded by a
+// backslash, does not separate words. The quotes are preserved.
+func ShellWords(s string) []string {
+	return splitQuoted(s, "'\"", true /* backslash */, "")
+}
+
+// SQLWords is like Words, but it respects SQL quoting: the whitespace
+// within string literals ('...') or quoted identifiers ("...") does
+// not separate words. Commas, semicolons and parentheses also
+// separate words, and are omitted. The quotes are preserved.
+func SQLWords(s string) []string {
+	return splitQuoted(s, "'\"", false /* backslash */, ",;()")
+}
+
+// splitQuoted splits the input into words separated by whitespace or
+// by one of the separators, except within quotes. If backslash is
+// set, a backslash outside of single quotes escapes the next
+// character.
+func splitQuoted(s string, quotes string, backslash bool, separators string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if backslash && r == '\\' && quote != '\'' {
+				escaped = true
+			}
+		case unicode.IsSpace(r) || strings.ContainsRune(separators, r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		case strings.ContainsRune(quotes, r):
+			quote = r
+		case backslash && r == '\\':
+			escaped = true
+		}
+		word.WriteRune(r)
+		inWord = true
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
@@ -0,0 +1,49 @@
+package computil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	td := []struct {
+		input    string
+		words    []string
+		shell    []string
+		sqlWords []string
+	}{
+		{``, nil, nil, nil},
+		{"  a  b\tc\nd ", []string{`a`, `b`, `c`, `d`}, []string{`a`, `b`, `c`, `d`}, []string{`a`, `b`, `c`, `d`}},
+		{`cp "a b" c\ d 'e f'`,
+			[]string{`cp`, `"a`, `b"`, `c\`, `d`, `'e`, `f'`},
+			[]string{`cp`, `"a b"`, `c\ d`, `'e f'`},
+			[]string{`cp`, `"a b"`, `c\`, `d`, `'e f'`}},
+		{`echo 'a\' "b\" c"`,
+			[]string{`echo`, `'a\'`, `"b\"`, `c"`},
+			[]string{`echo`, `'a\'`, `"b\" c"`},
+			[]string{`echo`, `'a\'`, `"b\"`, `c"`}},
+		{`insert into t values (1, 'it''s ok');`,
+			[]string{`insert`, `into`, `t`, `values`, `(1,`, `'it''s`, `ok');`},
+			[]string{`insert`, `into`, `t`, `values`, `(1,`, `'it''s ok');`},
+			[]string{`insert`, `into`, `t`, `values`, `1`, `'it''s ok'`}},
+		{"select \"my col\" from t;", nil, nil, []string{`select`, `"my col"`, `from`, `t`}},
+	}
+
+	for _, tc := range td {
+		if tc.words != nil {
+			if res := Words(tc.input); !reflect.DeepEqual(res, tc.words) {
+				t.Errorf("Words(%q): expected %q, got %q", tc.input, tc.words, res)
+			}
+		}
+		if tc.shell != nil {
+			if res := ShellWords(tc.input); !reflect.DeepEqual(res, tc.shell) {
+				t.Errorf("ShellWords(%q): expected %q, got %q", tc.input, tc.shell, res)
+			}
+		}
+		if tc.sqlWords != nil || tc.input == "" {
+			if res := SQLWords(tc.input); !reflect.DeepEqual(res, tc.sqlWords) {
+				t.Errorf("SQLWords(%q): expected %q, got %q", tc.input, tc.sqlWords, res)
+			}
+		}
+	}
+}
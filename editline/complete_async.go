@@ -0,0 +1,108 @@
+package editline
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// AsyncAutoCompleteFn is like AutoCompleteFn, but is called in the
+// background so that the editor remains responsive while the
+// completions are computed. A spinner is displayed in the meantime.
+//
+// The context is canceled if the user presses a key before the
+// function returns, in which case the results are discarded.
+type AsyncAutoCompleteFn func(ctx context.Context, entireInput [][]rune, line, col int) (msg string, comp Completions)
+
+// pendingCompletion describes an asynchronous completion in progress.
+type pendingCompletion struct {
+	// id identifies the request, so that results from
+	// earlier (canceled) requests can be recognized and discarded.
+	id int
+	// cancel cancels the request. nil if there is no request in
+	// progress.
+	cancel context.CancelFunc
+	// value, line and col are the input and cursor position at the
+	// time the request was started.
+	value     [][]rune
+	line, col int
+}
+
+// asyncCompletionMsg is sent when an AsyncAutoCompleteFn returns.
+type asyncCompletionMsg struct {
+	id    int
+	msg   string
+	comps Completions
+}
+
+// startAsyncCompletion runs the AsyncAutoCompleteFn in the background.
+func (m *Model) startAsyncCompletion() tea.Cmd {
+	m.cancelAsyncCompletion()
+	m.showCompletions = false
+	m.completions.Blur()
+
+	// ValueRunes returns the internal buffers of the text area.
+	// The completion function runs concurrently with further
+	// edits, so give it a copy, including of the outer slice.
+	src := m.text.ValueRunes()
+	value := make([][]rune, len(src))
+	for i, l := range src {
+		value[i] = append([]rune(nil), l...)
+	}
+	line, col := m.text.Line(), m.text.CursorPos()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.compSeq++
+	m.compPending = pendingCompletion{
+		id:     m.compSeq,
+		cancel: cancel,
+		value:  value,
+		line:   line,
+		col:    col,
+	}
+	m.KeyMap.AbortCompletion.SetEnabled(true)
+
+	fn, id := m.AsyncAutoComplete, m.compSeq
+	run := func() tea.Msg {
+		msg, comps := fn(ctx, value, line, col)
+		return asyncCompletionMsg{id: id, msg: msg, comps: comps}
+	}
+	return tea.Batch(run, m.completions.StartLoading(), m.updateTextSz())
+}
+
+// cancelAsyncCompletion cancels the asynchronous completion in
+// progress, if any.
+func (m *Model) cancelAsyncCompletion() {
+	if m.compPending.cancel != nil {
+		m.compPending.cancel()
+	}
+	m.compPending = pendingCompletion{}
+	m.completions.StopLoading()
+	m.KeyMap.AbortCompletion.SetEnabled(false)
+}
+
+// asyncCompletionDone applies the results of an asynchronous
+// completion, unless they are stale.
+func (m *Model) asyncCompletionDone(msg asyncCompletionMsg) tea.Cmd {
+	if m.compPending.cancel == nil || msg.id != m.compPending.id ||
+		m.text.Line() != m.compPending.line ||
+		m.text.CursorPos() != m.compPending.col ||
+		!sameValue(m.text.ValueRunes(), m.compPending.value) {
+		// The request was canceled or the input changed in the meantime.
+		return nil
+	}
+	m.cancelAsyncCompletion()
+	return tea.Batch(m.updateTextSz(), m.applyCompletions(msg.msg, msg.comps))
+}
+
+func sameValue(a, b [][]rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if string(a[i]) != string(b[i]) {
+			return false
+		}
+	}
+	return true
+}
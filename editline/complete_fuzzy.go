@@ -0,0 +1,96 @@
+package editline
+
+import (
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/knz/bubbline/complete"
+)
+
+// showFuzzyCompletions filters the completions by fuzzy matching
+// against the word under the cursor. If there is just one match, it
+// is inserted directly; otherwise the completion menu is displayed.
+func (m *Model) showFuzzyCompletions(comps Completions) tea.Cmd {
+	m.compPattern = m.completionWord(comps)
+	m.compTyped = 0
+	pattern := string(m.compPattern)
+
+	var numMatches int
+	var match complete.Entry
+	for catIdx := 0; catIdx < comps.NumCategories(); catIdx++ {
+		for eIdx := 0; eIdx < comps.NumEntries(catIdx); eIdx++ {
+			e := comps.Entry(catIdx, eIdx)
+			if _, _, ok := complete.FuzzyMatch(pattern, e.Title()); ok {
+				numMatches++
+				match = e
+			}
+		}
+	}
+	switch numMatches {
+	case 0:
+		// No completions. Do nothing.
+		return nil
+	case 1:
+		m.insertCandidate(comps.Candidate(match))
+		return m.updateTextSz()
+	}
+
+	m.showCompletions = true
+	m.compCandidates = comps
+	// The typed characters narrow the candidates, so the filter of
+	// the completion lists is not needed.
+	m.completions.SetFilteringEnabled(false)
+	m.completions.SetValues(comps)
+	m.completions.SetPattern(pattern)
+	m.completions.Focus()
+	return m.updateTextSz()
+}
+
+// completionWord returns the part of the word being completed that
+// is to the left of the cursor.
+func (m *Model) completionWord(comps Completions) []rune {
+	var c Candidate
+	for catIdx := 0; catIdx < comps.NumCategories() && c == nil; catIdx++ {
+		if comps.NumEntries(catIdx) > 0 {
+			c = comps.Candidate(comps.Entry(catIdx, 0))
+		}
+	}
+	if c == nil {
+		return nil
+	}
+	line := m.text.ValueRunes()[m.text.Line()]
+	col := m.text.CursorPos()
+	start := clamp(col-(c.DeleteLeft()-c.MoveRight()), 0, col)
+	return append([]rune(nil), line[start:col]...)
+}
+
+// narrowCompletions processes a key press while fuzzy completions
+// are displayed. Characters typed are inserted in the input and
+// narrow down the completion candidates. Returns false if the key
+// does not apply to the completions.
+func (m *Model) narrowCompletions(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyRunes && !msg.Alt && !msg.Paste && !containsSpace(msg.Runes):
+		m.compPattern = append(m.compPattern, msg.Runes...)
+		m.compTyped += len(msg.Runes)
+	case key.Matches(msg, m.KeyMap.DeleteCharacterBackward) && len(m.compPattern) > 0:
+		m.compPattern = m.compPattern[:len(m.compPattern)-1]
+		m.compTyped--
+	default:
+		return false, nil
+	}
+	var cmd tea.Cmd
+	m.text, cmd = m.text.Update(msg)
+	m.completions.SetPattern(string(m.compPattern))
+	return true, tea.Batch(cmd, m.updateTextSz())
+}
+
+func containsSpace(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.IsSpace(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package editline
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/knz/bubbline/editline/internal/textarea"
+)
+
+// EchoMode sets how the input is displayed.
+type EchoMode = textarea.EchoMode
+
+const (
+	// EchoNormal displays the input as is.
+	EchoNormal = textarea.EchoNormal
+
+	// EchoPassword displays EchoCharacter instead of each character
+	// of the input.
+	EchoPassword = textarea.EchoPassword
+
+	// EchoNone displays nothing.
+	EchoNone = textarea.EchoNone
+)
+
+// secret returns true if the current input is a secret, i.e. it is
+// not displayed as is.
+func (m *Model) secret() bool {
+	return m.text.EchoMode != EchoNormal
+}
+
+// resetText resets the text area, applying the echo mode.
+func (m *Model) resetText() {
+	if m.secret() || m.EchoMode != EchoNormal {
+		// Ensure the previous input does not remain in memory.
+		m.text.Wipe()
+	} else {
+		m.text.Reset()
+	}
+	m.text.EchoMode = m.EchoMode
+	m.text.EchoCharacter = m.EchoCharacter
+}
+
+// helpKeyMap returns the key bindings to display in the help,
+// omitting those not available when entering a secret.
+func (m *Model) helpKeyMap() KeyMap {
+	k := m.KeyMap
+	if m.secret() {
+		for _, b := range []*key.Binding{
+			&k.AutoComplete, &k.ExternalEdit, &k.SearchBackward, &k.SearchForward,
+			&k.HistoryPrevious, &k.HistoryNext, &k.YankLastArg, &k.Debug,
+		} {
+			b.SetEnabled(false)
+		}
+	}
+	return k
+}
@@ -6,18 +6,21 @@ import (
 	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"regexp"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/knz/bubbline/complete"
 	"github.com/knz/bubbline/editline/internal/textarea"
+	"github.com/knz/bubbline/history"
 	rw "github.com/mattn/go-runewidth"
 	"github.com/muesli/reflow/wordwrap"
 )
@@ -31,8 +34,8 @@ type Style struct {
 	Editor textarea.Style
 
 	SearchInput struct {
-		PromptStyle      lipgloss.Style
-		TextStyle        lipgloss.Style
+		PromptStyle lipgloss.Style
+		TextStyle   lipgloss.Style
 		// BackgroundStyle is unused.
 		//
 		// Deprecated: kept for backward compatibility; the underlying
@@ -41,6 +44,10 @@ type Style struct {
 		PlaceholderStyle lipgloss.Style
 		CursorStyle      lipgloss.Style
 	}
+
+	// SearchMatch is applied to the text matched by the history
+	// search pattern in the entry found.
+	SearchMatch lipgloss.Style
 }
 
 // DefaultStyles returns the default styles for focused and blurred states for
@@ -50,6 +57,7 @@ func DefaultStyles() (Style, Style) {
 	fs := Style{Editor: ts1}
 	bs := Style{Editor: ts2}
 	fs.SearchInput.PlaceholderStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	fs.SearchMatch = lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("11"))
 	return fs, bs
 }
 
@@ -65,6 +73,7 @@ type KeyMap struct {
 	Refresh         key.Binding
 	AbortSearch     key.Binding
 	SearchBackward  key.Binding
+	SearchForward   key.Binding
 	HistoryPrevious key.Binding
 	HistoryNext     key.Binding
 	Debug           key.Binding
@@ -75,6 +84,13 @@ type KeyMap struct {
 	ReflowLine      key.Binding
 	ReflowAll       key.Binding
 	ExternalEdit    key.Binding
+	Undo            key.Binding
+	Redo            key.Binding
+	AbortCompletion key.Binding
+
+	ToggleSearchPicker key.Binding
+	YankLastArg        key.Binding
+	DigitArgument      key.Binding
 }
 
 // DefaultKeyMap is the default set of key bindings.
@@ -91,14 +107,23 @@ var DefaultKeyMap = KeyMap{
 	EndOfInput:      key.NewBinding(key.WithKeys("ctrl+d"), key.WithHelp("C-d", "erase/stop")),
 	AbortSearch:     key.NewBinding(key.WithKeys("ctrl+g"), key.WithDisabled()),
 	SearchBackward:  key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("C-r", "search hist"), key.WithDisabled()),
+	SearchForward:   key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("C-s", "search hist fwd"), key.WithDisabled()),
 	HistoryPrevious: key.NewBinding(key.WithKeys("alt+p"), key.WithHelp("M-p", "prev history entry"), key.WithDisabled()),
 	HistoryNext:     key.NewBinding(key.WithKeys("alt+n"), key.WithHelp("M-n", "next history entry"), key.WithDisabled()),
-	HideShowPrompt:  key.NewBinding(key.WithKeys("alt+."), key.WithHelp("M-.", "hide/show prompt")),
+	HideShowPrompt:  key.NewBinding(key.WithKeys("alt+,"), key.WithHelp("M-,", "hide/show prompt")),
 	MoreHelp:        key.NewBinding(key.WithKeys("alt+?"), key.WithHelp("M-?", "toggle key help")),
 	ReflowLine:      key.NewBinding(key.WithKeys("alt+q"), key.WithHelp("M-q", "reflow line")),
 	ReflowAll:       key.NewBinding(key.WithKeys("alt+Q", "alt+`"), key.WithHelp("M-S-q/M-`", "reflow all")),
 	Debug:           key.NewBinding(key.WithKeys("ctrl+_", "ctrl+@"), key.WithHelp("C-_/C-@", "debug mode"), key.WithDisabled()),
 	ExternalEdit:    key.NewBinding(key.WithKeys("alt+f2", "alt+2"), key.WithHelp("M-2/M-F2", "external edit")),
+	Undo:            key.NewBinding(key.WithKeys("ctrl+_", "ctrl+x ctrl+u"), key.WithHelp("C-_", "undo")),
+	Redo:            key.NewBinding(key.WithKeys("alt+_"), key.WithHelp("M-_", "redo")),
+	AbortCompletion: key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("C-g", "cancel completion"), key.WithDisabled()),
+
+	ToggleSearchPicker: key.NewBinding(key.WithKeys("alt+r"), key.WithHelp("M-r", "toggle search picker")),
+	YankLastArg:        key.NewBinding(key.WithKeys("alt+."), key.WithHelp("M-.", "last arg")),
+	DigitArgument: key.NewBinding(key.WithKeys("alt+0", "alt+1", "alt+2", "alt+3", "alt+4",
+		"alt+5", "alt+6", "alt+7", "alt+8", "alt+9")),
 }
 
 // Model represents a widget that supports multi-line entry with
@@ -121,6 +146,11 @@ type Model struct {
 	// Only takes effect at Reset() or Focus().
 	Placeholder string
 
+	// Highlighter, if set, is called to style the text of the input,
+	// for example for syntax highlighting.
+	// Only takes effect at Reset() or Focus().
+	Highlighter HighlightFn
+
 	// CheckInputComplete is called when the Enter key is pressed.  It
 	// determines whether a newline character should be added to the
 	// input (callback returns false) or whether the input should
@@ -135,6 +165,41 @@ type Model struct {
 	// AutoComplete is the AutoCompleteFn to use.
 	AutoComplete AutoCompleteFn
 
+	// AsyncAutoComplete, if set, is used instead of AutoComplete. It
+	// runs in the background, so that the editor remains responsive
+	// while the completions are computed.
+	AsyncAutoComplete AsyncAutoCompleteFn
+
+	// FuzzyCompletion, if set, filters and ranks the completion
+	// candidates by fuzzy matching against the word under the cursor.
+	// Typing more characters while the completions are displayed
+	// narrows down the candidates further.
+	FuzzyCompletion bool
+
+	// AutoSuggest, if true, displays an inline suggestion after the
+	// cursor when the cursor is at the end of the input. The
+	// suggestion can be accepted with the CharacterForward or LineEnd
+	// keys, or one word at a time with the WordForward key.
+	AutoSuggest bool
+
+	// Suggest is the source of inline suggestions when AutoSuggest is
+	// enabled. If nil, the most recent history entry that starts with
+	// the input is suggested, among the 1000 most recent entries.
+	Suggest SuggestFn
+
+	// EchoMode determines how the input is displayed. Modes other than
+	// EchoNormal are meant to enter secrets, such as passwords: history
+	// recording, history navigation and search, autocompletion,
+	// inline suggestions, external editing and the debug view are then
+	// disabled, and the input is overwritten in memory upon Reset.
+	// Only takes effect at Reset().
+	EchoMode EchoMode
+
+	// EchoCharacter is displayed instead of each character of the
+	// input in the EchoPassword mode.
+	// Only takes effect at Reset().
+	EchoCharacter rune
+
 	// CharLimit is the maximum size of the input in characters.
 	// Set to zero or less for no limit.
 	CharLimit int
@@ -158,6 +223,74 @@ type Model struct {
 	// if it is equal to the last one added.
 	DedupHistory bool
 
+	// EraseDupHistory, if true, removes the older entries equal to a
+	// new entry when it is added to the history, so that the entry
+	// moves to the end, like HISTCONTROL=erasedups in bash. The
+	// duplicates are also removed from the history set all at once.
+	// Ephemeral entries, which are not saved, do not remove the older
+	// entries.
+	EraseDupHistory bool
+
+	// DedupWhitespace, if true, makes DedupHistory and EraseDupHistory
+	// ignore the differences in whitespace when comparing entries.
+	DedupWhitespace bool
+
+	// HistoryExpansion, if true, performs history expansion in the
+	// manner of bash (e.g. "!!", "!$" or "^old^new") when the input is
+	// complete. See history.Expand for details. If the expansion fails,
+	// the error is printed and the input is not completed.
+	HistoryExpansion bool
+
+	// HistoryVerify, if true, displays the result of history expansion
+	// in the editor for review, instead of completing the input, like
+	// "shopt -s histverify" in bash.
+	HistoryVerify bool
+
+	// FilterHistoryByPrefix, if true, makes history navigation only
+	// recall the entries that start with the text before the cursor
+	// when the navigation starts, like in zsh and fish. Duplicate
+	// entries are skipped, and the cursor stays at the end of the
+	// prefix.
+	FilterHistoryByPrefix bool
+
+	// HistoryIgnoreSpace, if true, keeps the entries that start with a
+	// space in the history of the current session only, like
+	// HISTCONTROL=ignorespace in bash: they are not saved to the
+	// history file.
+	HistoryIgnoreSpace bool
+
+	// HistoryIgnorePatterns keeps the entries that match one of the
+	// patterns in the history of the current session only.
+	HistoryIgnorePatterns []*regexp.Regexp
+
+	// HistoryRedact, if set, is called on the entries added to the
+	// history, for example to mask passwords. The redacted text
+	// replaces the entry, also in the history of the current session,
+	// so that secrets do not linger in memory.
+	HistoryRedact func(entry string) string
+
+	// MaxKillRingSize is the maximum number of entries in the kill
+	// ring, used by the yank commands. Set to zero for no limit.
+	// Only takes effect at Reset().
+	MaxKillRingSize int
+
+	// UndoMemoryLimit is the maximum amount of memory, in bytes, used
+	// to store the undo history of the current input. Set to zero for
+	// no limit.
+	// Only takes effect at Reset().
+	UndoMemoryLimit int
+
+	// Indent is the text inserted at the beginning of lines by the
+	// indent command (C-x tab).
+	// Only takes effect at Reset().
+	Indent string
+
+	// CommentPrefix is the text inserted at the beginning of lines by
+	// the comment toggle command (M-;). Set to the empty string to
+	// disable the command.
+	// Only takes effect at Reset().
+	CommentPrefix string
+
 	// DeleteCharIfNotEOF, if true, causes the EndOfInput key binding
 	// to be translated to delete-character-forward when it is not
 	// entered at the beginning of a line.
@@ -176,6 +309,20 @@ type Model struct {
 	// Only takes effect at Reset().
 	NextPrompt string
 
+	// PromptFunc, if defined, computes the prompt displayed before
+	// each entry line instead of Prompt and NextPrompt. It is given
+	// the current editing mode, so that the prompt can include a mode
+	// indicator. The prompts for the lines after the first should all
+	// have the same width.
+	// Only takes effect at Reset().
+	PromptFunc func(line int, mode EditMode) string
+
+	// EditMode is the editing mode at the start of each input. Use
+	// ViInsertMode or ViNormalMode for the vi key bindings.
+	// Only takes effect at Reset(); use SetEditMode() to change
+	// the mode immediately.
+	EditMode EditMode
+
 	// Reflow, if defined, is used for the reflowing commands (M-q/M-Q).
 	// The info returned value, if any, is displayed as an informational
 	// message above the editor.
@@ -189,10 +336,39 @@ type Model struct {
 	// SearchPromptInvalid is the prompt displayed before the history search pattern,
 	// when the pattern is invalid.
 	SearchPromptInvalid string
+	// SearchForwardPrompt, SearchForwardPromptNotFound and
+	// SearchForwardPromptInvalid are the prompts displayed instead of
+	// the above when searching forward.
+	SearchForwardPrompt         string
+	SearchForwardPromptNotFound string
+	SearchForwardPromptInvalid  string
+
+	// SearchMode determines how the history search pattern is
+	// interpreted. The default is SearchGlob.
+	SearchMode SearchMode
 
 	// CaseSensitiveSearch, if enabled, makes history search case-sensitive.
 	CaseSensitiveSearch bool
 
+	// HistoryPicker, if true, makes the SearchBackward key display a
+	// menu of the history entries matching the search pattern, ranked
+	// by fuzzy matching, instead of the single most recent match. The
+	// menu lists the 1000 most recent entries at most. The
+	// SearchBackward key in the menu switches to the classic search,
+	// and the ToggleSearchPicker key switches between the two.
+	HistoryPicker bool
+
+	// SearchTimestampFormat, if non-empty, is the layout (see
+	// time.Layout) used to display when the history entry found by
+	// history search was entered, next to the search pattern.
+	SearchTimestampFormat string
+
+	// Tokenizer splits the history entries into words for the
+	// YankLastArg command. If nil, computil.Words is used;
+	// computil.ShellWords and computil.SQLWords respect shell and SQL
+	// quoting, respectively.
+	Tokenizer TokenizeFn
+
 	// ShowLineNumbers if true shows line numbers at the beginning
 	// of each input line.
 	// Only takes effect at Reset() or Focus().
@@ -204,24 +380,54 @@ type Model struct {
 
 	showCompletions bool
 	compCandidates  Completions
-	completions     complete.Model
-
-	history []string
+	compPending     pendingCompletion
+	compSeq         int
+	// compPattern is the word matched against the completion
+	// candidates when FuzzyCompletion is set.
+	compPattern []rune
+	// compTyped is the number of characters typed (or deleted, if
+	// negative) while the completions are displayed.
+	compTyped   int
+	completions complete.Model
+
+	history history.Store
 	hctrl   struct {
 		pattern textinput.Model
 		c       struct {
 			// searching is true when we're currently searching.
-			searching   bool
+			searching bool
+			// picker is true when the search uses the history picker.
+			picker bool
+			// forward is true when searching towards the most recent
+			// entries.
+			forward     bool
 			prevPattern string
 			cursor      int
+			// matches is the position of the text matched by the search
+			// pattern in the entry found, in runes, for highlighting.
+			matches [][2]int
 			// value prior to the search starting.
 			valueSaved bool
 			prevValue  string
 			prevCursor int
 		}
+		// nav is the state of the history navigation filtered by
+		// prefix.
+		nav prefixNav
 	}
 	promptHidden bool
 
+	// yankArg is the state of the YankLastArg command.
+	yankArg yankArgState
+
+	// keySeqPrefix is the sequence of keys entered so far, when they
+	// form the beginning of a multi-key binding.
+	keySeqPrefix string
+
+	// promptMode is the editing mode for which the prompt was last
+	// computed.
+	promptMode EditMode
+
 	help help.Model
 
 	text      textarea.Model
@@ -241,24 +447,33 @@ type Model struct {
 func New(width, height int) *Model {
 	focusedStyle, blurredStyle := DefaultStyles()
 	m := &Model{
-		text:                 textarea.New(),
-		Err:                  nil,
-		KeyMap:               DefaultKeyMap,
-		MaxHistorySize:       0, // no limit
-		Reflow:               DefaultReflow,
-		DedupHistory:         true,
-		DeleteCharIfNotEOF:   true,
-		FocusedStyle:         focusedStyle,
-		BlurredStyle:         blurredStyle,
-		Placeholder:          "",
-		Prompt:               "> ",
-		NextPrompt:           "",
-		SearchPrompt:         "bck:",
-		SearchPromptNotFound: "bck?",
-		SearchPromptInvalid:  "bck!",
-		ShowLineNumbers:      false,
-		help:                 help.New(),
-		completions:          complete.New(),
+		text:                        textarea.New(),
+		Err:                         nil,
+		KeyMap:                      DefaultKeyMap,
+		MaxHistorySize:              0, // no limit
+		MaxKillRingSize:             60,
+		UndoMemoryLimit:             1 << 20,
+		Indent:                      "  ",
+		CommentPrefix:               "# ",
+		Reflow:                      DefaultReflow,
+		DedupHistory:                true,
+		DeleteCharIfNotEOF:          true,
+		FocusedStyle:                focusedStyle,
+		BlurredStyle:                blurredStyle,
+		Placeholder:                 "",
+		Prompt:                      "> ",
+		EchoCharacter:               '*',
+		NextPrompt:                  "",
+		SearchPrompt:                "bck:",
+		SearchPromptNotFound:        "bck?",
+		SearchPromptInvalid:         "bck!",
+		SearchForwardPrompt:         "fwd:",
+		SearchForwardPromptNotFound: "fwd?",
+		SearchForwardPromptInvalid:  "fwd!",
+		ShowLineNumbers:             false,
+		help:                        help.New(),
+		completions:                 complete.New(),
+		history:                     history.NewMemoryStore(nil),
 	}
 	if width != 0 || height != 0 {
 		m.hasNewSize = true
@@ -282,15 +497,38 @@ func (m *Model) SetExternalEditorEnabled(enable bool, extension string) {
 	m.externalEditorExt = extension
 }
 
+// HistoryEntry is a history entry with its metadata.
+type HistoryEntry = history.Entry
+
 // SetHistory sets the history navigation list all at once.
 func (m *Model) SetHistory(h []string) {
+	m.SetHistoryEntries(history.FromTexts(h))
+}
+
+// SetHistoryEntries sets the history navigation list all at once,
+// including the metadata of the entries. The entries are kept in
+// memory.
+func (m *Model) SetHistoryEntries(h []HistoryEntry) {
+	if m.EraseDupHistory {
+		h = history.Dedup(h, m.DedupWhitespace)
+	}
 	if m.MaxHistorySize != 0 && len(h) > m.MaxHistorySize {
 		h = h[len(h)-m.MaxHistorySize:]
 	}
-	m.history = make([]string, 0, len(h))
-	m.history = append(m.history, h...)
-	m.checkHistoryEnabled()
-	m.resetNavCursor()
+	m.SetHistoryStore(history.NewMemoryStore(append(make([]HistoryEntry, 0, len(h)), h...)))
+}
+
+// SetKillRing sets the entries in the kill ring all at once, most
+// recent last.
+func (m *Model) SetKillRing(entries []string) {
+	m.text.MaxKillRingSize = m.MaxKillRingSize
+	m.text.SetKillRing(entries)
+}
+
+// GetKillRing retrieves all the entries in the kill ring, most
+// recent last.
+func (m *Model) GetKillRing() []string {
+	return m.text.KillRing()
 }
 
 // SetDebugEnabled enables/disables the debug mode binding.
@@ -304,28 +542,71 @@ func (m *Model) SetDebugEnabled(enable bool) {
 
 // GetHistory retrieves all the entries in the history navigation list.
 func (m *Model) GetHistory() []string {
-	return m.history
+	return history.Texts(m.historyEntries())
 }
 
-// AddHistoryEntry adds an entry to the history navigation list.
+// GetHistoryEntries retrieves all the entries in the history
+// navigation list, including their metadata. With a store configured
+// with SetHistoryStore, all the entries are read from the store.
+func (m *Model) GetHistoryEntries() []HistoryEntry {
+	return m.historyEntries()
+}
+
+// AddHistoryEntry adds an entry to the history navigation list. The
+// entry is timestamped with the current time, and the privacy
+// settings are applied with FilterHistoryEntry.
 func (m *Model) AddHistoryEntry(s string) {
-	// Only add a new entry if it doesn't duplicate the last one.
-	if len(m.history) == 0 || !(m.DedupHistory && s == m.history[len(m.history)-1]) {
-		m.history = append(m.history, s)
+	m.AppendHistoryEntry(m.FilterHistoryEntry(HistoryEntry{Text: s, Time: time.Now()}))
+}
+
+// AppendHistoryEntry adds an entry with its metadata to the history
+// navigation list, as-is.
+func (m *Model) AppendHistoryEntry(e HistoryEntry) {
+	if m.secret() {
+		// Secrets are not recorded.
+		return
+	}
+	s, inMemory := m.history.(*history.MemoryStore)
+	if !inMemory {
+		// Only add a new entry if it doesn't duplicate the last one.
+		if n := m.history.Len(); n == 0 || !(m.DedupHistory && history.SameText(e.Text, m.historyAt(n-1).Text, m.DedupWhitespace)) {
+			_ = m.history.Append(e)
+		}
+		m.checkHistoryEnabled()
+		m.resetNavCursor()
+		return
+	}
+	h := s.Entries()
+	if m.EraseDupHistory && !e.Ephemeral {
+		// Remove the older duplicates. Ephemeral entries do not replace
+		// persistent ones, which would then be missing from the history
+		// file when it is saved.
+		dedup := h[:0]
+		for _, o := range h {
+			if !history.SameText(o.Text, e.Text, m.DedupWhitespace) {
+				dedup = append(dedup, o)
+			}
+		}
+		h = append(dedup, e)
+	} else if len(h) == 0 || !(m.DedupHistory && history.SameText(e.Text, h[len(h)-1].Text, m.DedupWhitespace)) {
+		// Only add a new entry if it doesn't duplicate the last one.
+		h = append(h, e)
 	}
 	// Truncate if needed.
-	if m.MaxHistorySize != 0 && len(m.history) > m.MaxHistorySize {
-		copy(m.history, m.history[1:])
-		m.history = m.history[:m.MaxHistorySize]
+	if m.MaxHistorySize != 0 && len(h) > m.MaxHistorySize {
+		copy(h, h[1:])
+		h = h[:m.MaxHistorySize]
 	}
+	s.SetEntries(h)
 	m.checkHistoryEnabled()
 	m.resetNavCursor()
 }
 
 func (m *Model) checkHistoryEnabled() {
-	enabled := len(m.history) > 0
+	enabled := m.history.Len() > 0
 	m.KeyMap.AbortSearch.SetEnabled(enabled)
 	m.KeyMap.SearchBackward.SetEnabled(enabled)
+	m.KeyMap.SearchForward.SetEnabled(enabled)
 	m.KeyMap.HistoryPrevious.SetEnabled(enabled)
 	m.KeyMap.HistoryNext.SetEnabled(enabled)
 }
@@ -342,6 +623,7 @@ func (m *Model) Focus() tea.Cmd {
 	_ = m.hctrl.pattern.Cursor.SetMode(m.CursorMode)
 	m.text.KeyMap = m.KeyMap.KeyMap
 	m.text.Placeholder = m.Placeholder
+	m.text.Highlighter = m.Highlighter
 	m.text.ShowLineNumbers = m.ShowLineNumbers
 	m.text.FocusedStyle = m.FocusedStyle.Editor
 	m.text.BlurredStyle = m.BlurredStyle.Editor
@@ -381,19 +663,27 @@ func (m *Model) currentlySearching() bool {
 	return m.hctrl.c.searching
 }
 
-func (m *Model) historyStartSearch() {
+func (m *Model) historyStartSearch(forward bool) {
 	m.KeyMap.AbortSearch.SetEnabled(true)
 	m.hctrl.c.searching = true
+	m.hctrl.c.forward = forward
 	m.hctrl.c.prevPattern = ""
-	m.hctrl.pattern.Prompt = m.SearchPrompt
+	m.hctrl.c.matches = nil
+	m.setSearchPrompt(true, true)
 	m.hctrl.pattern.Reset()
 	m.hctrl.pattern.Focus()
+	m.text.Checkpoint()
+	if forward && m.hctrl.c.valueSaved {
+		// Navigating the history already: search forward from the
+		// current entry.
+		return
+	}
 	m.saveValue()
 	m.resetNavCursor()
 }
 
 func (m *Model) resetNavCursor() {
-	m.hctrl.c.cursor = len(m.history)
+	m.hctrl.c.cursor = m.history.Len()
 }
 
 func (m *Model) cancelHistorySearch() (cmd tea.Cmd) {
@@ -424,48 +714,67 @@ func (m *Model) acceptSearch() {
 	m.text.Focus()
 }
 
+// searchTimestamp returns the time at which the history entry found
+// by history search was entered, formatted for display, or an empty
+// string if there is none or SearchTimestampFormat is not set.
+func (m *Model) searchTimestamp() string {
+	if m.SearchTimestampFormat == "" || m.hctrl.c.cursor >= m.history.Len() {
+		return ""
+	}
+	t := m.historyAt(m.hctrl.c.cursor).Time
+	if t.IsZero() {
+		return ""
+	}
+	return " (" + t.Format(m.SearchTimestampFormat) + ")"
+}
+
 func (m *Model) incrementalSearch(nextMatch bool) (cmd tea.Cmd) {
-	pat := m.hctrl.pattern.Value() + "*"
-	if !m.CaseSensitiveSearch {
-		pat = strings.ToLower(pat)
+	expr := m.searchExpr()
+	forward := m.hctrl.c.forward
+	// start is the first entry to consider.
+	start := m.hctrl.c.cursor - 1
+	if forward {
+		start = m.hctrl.c.cursor + 1
 	}
-	if pat == m.hctrl.c.prevPattern {
+	if expr == m.hctrl.c.prevPattern {
 		if !nextMatch {
 			// Nothing changed, and no request for incremental search: do nothing.
 			return
 		}
 		// Just nextMatch: continue incremental search below.
 	} else {
-		// Pattern changed, start again.
-		m.resetNavCursor()
-		m.hctrl.c.prevPattern = pat
+		// Pattern changed, start again: from the most recent entry
+		// when searching backward, from the current entry otherwise.
+		if forward {
+			start = m.hctrl.c.cursor
+		} else {
+			m.resetNavCursor()
+			start = m.hctrl.c.cursor - 1
+		}
+		m.hctrl.c.prevPattern = expr
+		m.hctrl.c.matches = nil
 	}
 
-	i := m.hctrl.c.cursor - 1
-	for ; i >= 0; i-- {
-		entry := m.history[i]
-		lentry := entry
-		if !m.CaseSensitiveSearch {
-			lentry = strings.ToLower(lentry)
-		}
-		for j := len(lentry) - len(pat) + 1; /* +1 to account for '*' */ j >= 0; j-- {
-			match, err := filepath.Match(pat, lentry[j:])
-			if err != nil {
-				m.hctrl.pattern.Prompt = m.SearchPromptInvalid
-				return cmd
-			}
-			if match {
-				// It's a match!
-				m.hctrl.pattern.Prompt = m.SearchPrompt
-				m.hctrl.c.cursor = i
-				return m.updateValue(entry, j)
-			}
-		}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		m.setSearchPrompt(false, false)
+		return cmd
 	}
-	if i < 0 {
-		// No match found.
-		m.hctrl.pattern.Prompt = m.SearchPromptNotFound
+	dir := history.Backward
+	if forward {
+		dir = history.Forward
 	}
+	if i, _ := m.history.Search(re, start, dir); i >= 0 {
+		// It's a match!
+		entry := m.historyAt(i).Text
+		loc := findMatch(re, entry, forward)
+		m.setSearchPrompt(true, true)
+		m.hctrl.c.cursor = i
+		m.setSearchMatch(entry, loc)
+		return m.updateValue(entry, loc[0])
+	}
+	// No match found.
+	m.setSearchPrompt(false, true)
 	return cmd
 }
 
@@ -486,7 +795,11 @@ func (m *Model) updateTextSz() (cmd tea.Cmd) {
 	textHeight := m.text.LogicalHeight()
 
 	remaining := m.maxHeight - 1
-	if m.showCompletions {
+	if m.completions.Loading() {
+		// Make space for the spinner.
+		remaining--
+	}
+	if m.showCompletions || m.pickerActive() {
 		// Don't let the completions exceed 2/3rds of the screen size.
 		ch := m.completions.GetMaxHeight()
 		if ch+textHeight > remaining {
@@ -527,18 +840,31 @@ func (m *Model) hidePrompt(b bool) {
 }
 
 func (m *Model) updatePrompt() {
+	m.promptMode = m.CurrentEditMode()
 	prompt, nextPrompt := m.Prompt, m.NextPrompt
-	if m.promptHidden {
-		prompt, nextPrompt = "", ""
-	}
-	promptWidth := max(rw.StringWidth(prompt), rw.StringWidth(nextPrompt))
-	m.text.Prompt = ""
-	m.text.SetPromptFunc(promptWidth, func(line int) string {
+	promptFn := func(line int) string {
 		if line == 0 {
 			return prompt
 		}
 		return nextPrompt
-	})
+	}
+	promptWidth := max(rw.StringWidth(prompt), rw.StringWidth(nextPrompt))
+	if m.PromptFunc != nil {
+		mode, fn := m.promptMode, m.PromptFunc
+		promptFn = func(line int) string { return fn(line, mode) }
+		// Use the same width in all modes, so that the text does not
+		// move when the mode changes.
+		promptWidth = 0
+		for _, mode := range []EditMode{EmacsMode, ViInsertMode, ViNormalMode} {
+			promptWidth = max(promptWidth, max(rw.StringWidth(fn(0, mode)), rw.StringWidth(fn(1, mode))))
+		}
+	}
+	if m.promptHidden {
+		promptFn = func(int) string { return "" }
+		promptWidth = 0
+	}
+	m.text.Prompt = ""
+	m.text.SetPromptFunc(promptWidth, promptFn)
 	// Recompute the width.
 	m.text.SetWidth(m.maxWidth - 1)
 }
@@ -550,34 +876,48 @@ func (m *Model) saveValue() {
 }
 
 func (m *Model) historyUp() (cmd tea.Cmd) {
+	if m.FilterHistoryByPrefix {
+		return m.prefixHistoryUp()
+	}
 	if m.hctrl.c.cursor == 0 {
 		return cmd
 	}
 	if !m.hctrl.c.valueSaved {
 		m.saveValue()
 	}
+	m.text.Checkpoint()
 	m.hctrl.c.cursor--
-	entry := m.history[m.hctrl.c.cursor]
+	entry := m.historyAt(m.hctrl.c.cursor).Text
 	return tea.Batch(cmd, m.updateValue(entry, len(entry)))
 }
 
 func (m *Model) historyDown() (cmd tea.Cmd) {
-	if m.hctrl.c.cursor >= len(m.history) {
+	if m.FilterHistoryByPrefix {
+		return m.prefixHistoryDown()
+	}
+	if m.hctrl.c.cursor >= m.history.Len() {
 		return cmd
 	}
 	if !m.hctrl.c.valueSaved {
 		m.saveValue()
 	}
+	m.text.Checkpoint()
 	m.hctrl.c.cursor++
-	if m.hctrl.c.cursor >= len(m.history) {
+	if m.hctrl.c.cursor >= m.history.Len() {
 		return m.restoreValue()
 	}
-	entry := m.history[m.hctrl.c.cursor]
+	entry := m.historyAt(m.hctrl.c.cursor).Text
 	return tea.Batch(cmd, m.updateValue(entry, len(entry)))
 }
 
 func (m *Model) autoComplete() (cmd tea.Cmd) {
 	msgs, comps := m.AutoComplete(m.text.ValueRunes(), m.text.Line(), m.text.CursorPos())
+	return m.applyCompletions(msgs, comps)
+}
+
+// applyCompletions displays the message and the completion candidates
+// returned by an autocompletion function.
+func (m *Model) applyCompletions(msgs string, comps Completions) (cmd tea.Cmd) {
 	if msgs != "" {
 		// TODO(knz): maybe display the help using a viewport widget?
 		cmd = tea.Batch(cmd, tea.Println(msgs))
@@ -589,10 +929,15 @@ func (m *Model) autoComplete() (cmd tea.Cmd) {
 		return cmd
 	}
 
+	if m.FuzzyCompletion {
+		return tea.Batch(cmd, m.showFuzzyCompletions(comps))
+	}
+
 	justOne := comps.NumCategories() == 1 && comps.NumEntries(0) == 1
 
 	hasPrefill, moveRight, deleteLeft, prefill, newCompletions := computePrefill(comps)
 	if hasPrefill {
+		m.text.Checkpoint()
 		m.text.CursorRight(moveRight)
 		m.text.DeleteCharactersBackward(deleteLeft)
 		m.text.InsertString(prefill)
@@ -608,8 +953,10 @@ func (m *Model) autoComplete() (cmd tea.Cmd) {
 		cmd = tea.Batch(cmd, m.updateTextSz())
 	}
 	if !justOne && newCompletions != nil {
+		m.compTyped = 0
 		m.showCompletions = true
 		m.compCandidates = newCompletions
+		m.completions.SetFilteringEnabled(true)
 		m.completions.SetValues(newCompletions)
 		m.completions.Focus()
 		// Clamp the completion widget to an approproiate height.
@@ -639,12 +986,13 @@ func (d doProgram) SetStderr(io.Writer) {}
 func (m *Model) Debug() string {
 	var buf strings.Builder
 	fmt.Fprintf(&buf, "lastEvent: %+v\n", m.lastEvent)
-	fmt.Fprintf(&buf, "history: %q\n", m.history)
+	fmt.Fprintf(&buf, "history: %q\n", m.GetHistory())
 	fmt.Fprintf(&buf, "hasNewSize: %v, w: %d, h: %d\n", m.hasNewSize, m.newWidth, m.newHeight)
 	fmt.Fprintf(&buf, "maxHeight: %d, maxWidth: %d\n", m.maxHeight, m.maxWidth)
 	fmt.Fprintf(&buf, "promptHidden: %v\n", m.promptHidden)
+	fmt.Fprintf(&buf, "keySeqPrefix: %q\n", m.keySeqPrefix)
 	fmt.Fprintf(&buf, "hctrl.c: %+v\n", m.hctrl.c)
-	fmt.Fprintf(&buf, "showComp: %v\n", m.showCompletions)
+	fmt.Fprintf(&buf, "showComp: %v, compPending: %v\n", m.showCompletions, m.compPending.cancel != nil)
 	fmt.Fprintf(&buf, "htctrl.pattern: %q\n", m.hctrl.pattern.Value())
 	return buf.String()
 }
@@ -699,6 +1047,7 @@ func (m *Model) reflowLine() (cmd tea.Cmd) {
 	if !changed {
 		return nil
 	}
+	m.text.Checkpoint()
 	m.text.ClearLine()
 	m.text.InsertString(newText)
 	if info != "" {
@@ -717,6 +1066,7 @@ func (m *Model) reflowAll() (cmd tea.Cmd) {
 	if !changed {
 		return nil
 	}
+	m.text.Checkpoint()
 	m.text.SetValue(newText)
 	if info != "" {
 		cmd = tea.Println(info)
@@ -726,8 +1076,10 @@ func (m *Model) reflowAll() (cmd tea.Cmd) {
 
 // handleSearching navigates through the history search.
 func (m *Model) handleSearching(imsg tea.Msg) (stillSearching bool, restMsg tea.Msg, cmd tea.Cmd) {
-	switch msg := imsg.(type) {
-	case tea.KeyMsg:
+	if m.hctrl.c.picker {
+		return m.handlePicker(imsg)
+	}
+	if msg, isKey := asKey(imsg); isKey {
 		switch {
 		case key.Matches(msg, m.KeyMap.EndOfInput):
 			if m.hctrl.pattern.Position() == 0 {
@@ -740,9 +1092,12 @@ func (m *Model) handleSearching(imsg tea.Msg) (stillSearching bool, restMsg tea.
 		case key.Matches(msg, m.KeyMap.AbortSearch, m.KeyMap.Interrupt):
 			return false, nil, m.cancelHistorySearch()
 
-		case key.Matches(msg, m.KeyMap.SearchBackward):
-			m.incrementalSearch(true /* nextMatch */)
-			return true, nil, nil
+		case key.Matches(msg, m.KeyMap.SearchBackward, m.KeyMap.SearchForward):
+			m.hctrl.c.forward = key.Matches(msg, m.KeyMap.SearchForward)
+			return true, nil, m.incrementalSearch(true /* nextMatch */)
+
+		case key.Matches(msg, m.KeyMap.ToggleSearchPicker):
+			return true, nil, m.startPicker()
 
 		case key.Matches(msg, m.KeyMap.AlwaysComplete):
 			m.acceptSearch()
@@ -755,7 +1110,7 @@ func (m *Model) handleSearching(imsg tea.Msg) (stillSearching bool, restMsg tea.
 			return false, nil, nil
 
 		default:
-			if !msg.Alt && (msg.Type == tea.KeySpace ||
+			if msg, ok := msg.(tea.KeyMsg); ok && !msg.Alt && (msg.Type == tea.KeySpace ||
 				msg.Type == tea.KeyBackspace ||
 				msg.Type == tea.KeyCtrlH ||
 				msg.Type == tea.KeyRunes) {
@@ -783,17 +1138,24 @@ func (m *Model) handleCompletions(imsg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 	v := m.completions.AcceptedValue
 	if v != nil {
-		c := m.compCandidates.Candidate(v)
-		m.text.CursorRight(c.MoveRight())
-		m.text.DeleteCharactersBackward(c.DeleteLeft())
-		m.text.InsertString(c.Replacement())
-		m.text.InsertRune(' ')
+		m.insertCandidate(m.compCandidates.Candidate(v))
 	}
 	m.showCompletions = false
 	m.completions.Blur()
 	return m, tea.Batch(cmd, m.updateTextSz())
 }
 
+// insertCandidate replaces the word being completed by the candidate.
+func (m *Model) insertCandidate(c Candidate) {
+	m.text.Checkpoint()
+	m.text.CursorRight(c.MoveRight())
+	// Also delete the characters typed since the completions were
+	// displayed.
+	m.text.DeleteCharactersBackward(c.DeleteLeft() + m.compTyped)
+	m.text.InsertString(c.Replacement())
+	m.text.InsertRune(' ')
+}
+
 func (m *Model) externalEdit() tea.Cmd {
 	ed := os.Getenv("EDITOR")
 	if ed == "" {
@@ -841,12 +1203,37 @@ func (m *Model) Update(imsg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	m.lastEvent = imsg
+	defer m.updateSuggestion()
 
-	switch msg := imsg.(type) {
-	case tea.KeyMsg:
+	if msg, ok := imsg.(spinner.TickMsg); ok && m.completions.Loading() {
+		_, nextCmd := m.completions.Update(msg)
+		return m, tea.Batch(cmd, nextCmd)
+	}
+
+	if msg, ok := imsg.(tea.KeyMsg); ok {
+		var consumed bool
+		if imsg, consumed = m.processKeySeq(msg); consumed {
+			return m, cmd
+		}
+	}
+	imsg = m.translateViKey(imsg)
+
+	if k, isKey := asKey(imsg); isKey && m.compPending.cancel != nil {
+		// Any key press cancels the completion in progress.
+		m.cancelAsyncCompletion()
+		cmd = tea.Batch(cmd, m.updateTextSz())
+		if key.Matches(k, m.KeyMap.AbortCompletion) {
+			return m, cmd
+		}
+	}
+
+	if msg, isKey := asKey(imsg); isKey {
 		switch {
 		case key.Matches(msg, m.KeyMap.Debug):
-			m.debugMode = !m.debugMode
+			m.debugMode = !m.debugMode && !m.secret()
+			// The Debug keys are shared with Undo and SetMark, which
+			// must not run too.
+			imsg = nil // consume message
 
 		case key.Matches(msg, m.KeyMap.SignalQuit):
 			return m, tea.Batch(cmd, tea.Exec(doProgram(func() {
@@ -880,12 +1267,19 @@ func (m *Model) Update(imsg tea.Msg) (tea.Model, tea.Cmd) {
 		default:
 			m.help.ShowAll = false
 
-			if m.showCompletions && !m.completions.MatchesKey(msg) {
-				// Currently displaying completions, but the widget
-				// is not accepting this keystroke. Cancel completions
-				// altogether and simply keep the input.
-				m.showCompletions = false
-				m.completions.Blur()
+			if m.showCompletions {
+				if msg, ok := msg.(tea.KeyMsg); !ok || !m.completions.MatchesKey(msg) {
+					if ok && m.FuzzyCompletion {
+						if narrowed, nextCmd := m.narrowCompletions(msg); narrowed {
+							return m, tea.Batch(cmd, nextCmd)
+						}
+					}
+					// Currently displaying completions, but the widget
+					// is not accepting this keystroke. Cancel completions
+					// altogether and simply keep the input.
+					m.showCompletions = false
+					m.completions.Blur()
+				}
 			}
 		}
 	}
@@ -912,12 +1306,31 @@ func (m *Model) Update(imsg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.err != nil {
 			return m, tea.Batch(cmd, tea.Printf("external editor error: %v", msg.err))
 		}
+		m.text.Checkpoint()
 		m.text.SetValue(msg.newText)
 		imsg = nil
 
-	case tea.KeyMsg:
+	case asyncCompletionMsg:
+		cmd = tea.Batch(cmd, m.asyncCompletionDone(msg))
+		imsg = nil
+
+	case tea.KeyMsg, textarea.KeySeqMsg:
+		k, _ := asKey(msg)
+		m.updateYankArgState(k)
 		switch {
-		case key.Matches(msg, m.KeyMap.AutoComplete):
+		case m.secret() && key.Matches(k,
+			m.KeyMap.AutoComplete, m.KeyMap.ExternalEdit,
+			m.KeyMap.SearchBackward, m.KeyMap.SearchForward,
+			m.KeyMap.HistoryPrevious, m.KeyMap.HistoryNext, m.KeyMap.YankLastArg):
+			// Not available when entering a secret.
+			imsg = nil // consume message
+
+		case key.Matches(k, m.KeyMap.AutoComplete):
+			if m.AsyncAutoComplete != nil {
+				cmd = tea.Batch(cmd, m.startAsyncCompletion())
+				imsg = nil // consume message
+				break
+			}
 			if m.AutoComplete == nil {
 				// Pass-through to the editor.
 				break
@@ -925,51 +1338,71 @@ func (m *Model) Update(imsg tea.Msg) (tea.Model, tea.Cmd) {
 			cmd = m.autoComplete()
 			imsg = 0 // consume message
 
-		case key.Matches(msg, m.KeyMap.EndOfInput):
+		case key.Matches(k, m.KeyMap.EndOfInput):
 			if m.text.AtBeginningOfEmptyLine() {
 				m.Err = io.EOF
 				stop = true
 				imsg = nil // consume message
 			} else if m.DeleteCharIfNotEOF {
+				m.text.Checkpoint()
 				m.text.DeleteCharacterForward()
 				imsg = nil // consume message
 			}
 
-		case key.Matches(msg, m.KeyMap.ExternalEdit):
+		case key.Matches(k, m.KeyMap.ExternalEdit):
 			cmd = m.externalEdit()
 			imsg = nil // consume message
 
-		case key.Matches(msg, m.KeyMap.SearchBackward):
-			m.historyStartSearch()
+		case key.Matches(k, m.KeyMap.DigitArgument):
+			// Alt+2 only gets here when ExternalEdit, which is checked
+			// first, is disabled.
+			m.digitArgument(k)
+			imsg = nil // consume message
+
+		case key.Matches(k, m.KeyMap.YankLastArg):
+			m.yankLastArg()
+			imsg = nil // consume message
+
+		case key.Matches(k, m.KeyMap.SearchBackward):
+			m.historyStartSearch(false /* forward */)
+			if m.HistoryPicker {
+				cmd = tea.Batch(cmd, m.startPicker())
+			}
+			imsg = nil // consume message
+
+		case key.Matches(k, m.KeyMap.SearchForward):
+			m.historyStartSearch(true /* forward */)
 			imsg = nil // consume message
 
-		case key.Matches(msg, m.KeyMap.HistoryPrevious):
+		case key.Matches(k, m.KeyMap.HistoryPrevious):
 			m.historyUp()
 			imsg = nil // consume message
 
-		case key.Matches(msg, m.KeyMap.HistoryNext):
+		case key.Matches(k, m.KeyMap.HistoryNext):
 			m.historyDown()
 			imsg = nil // consume message
 
-		case key.Matches(msg, m.KeyMap.Interrupt):
+		case key.Matches(k, m.KeyMap.Interrupt):
 			imsg = nil // consume message
 			if m.text.EmptyValue() {
 				m.Err = ErrInterrupted
 				stop = true
 				break
 			}
+			m.text.Checkpoint()
 			m.text.SetValue("")
 
-		case key.Matches(msg, m.KeyMap.AlwaysNewline):
+		case key.Matches(k, m.KeyMap.AlwaysNewline):
+			m.text.Checkpoint()
 			m.text.InsertNewline()
 			imsg = nil // consume message
 
-		case key.Matches(msg, m.KeyMap.AlwaysComplete):
+		case key.Matches(k, m.KeyMap.AlwaysComplete):
 			stop = true
 			imsg = nil // consume message
 
-		case key.Matches(msg, m.KeyMap.InsertNewline):
-			if m.CheckInputComplete == nil ||
+		case key.Matches(k, m.KeyMap.InsertNewline):
+			if m.CheckInputComplete == nil || m.secret() ||
 				m.CheckInputComplete(m.text.ValueRunes(), m.text.Line(), m.text.CursorPos()) {
 				stop = true
 
@@ -978,32 +1411,57 @@ func (m *Model) Update(imsg tea.Msg) (tea.Model, tea.Cmd) {
 				imsg = nil // consume message
 			}
 
-		case key.Matches(msg, m.KeyMap.LinePrevious):
-			if m.text.AtFirstLineOfInputAndView() {
+		case m.text.Suggestion() != "" && key.Matches(k, m.KeyMap.CharacterForward, m.KeyMap.LineEnd):
+			m.acceptSuggestion(false /* wordOnly */)
+			imsg = nil // consume message
+
+		case m.text.Suggestion() != "" && key.Matches(k, m.KeyMap.WordForward):
+			m.acceptSuggestion(true /* wordOnly */)
+			imsg = nil // consume message
+
+		case key.Matches(k, m.KeyMap.LinePrevious):
+			if m.text.AtFirstLineOfInputAndView() && !m.secret() {
 				m.historyUp()
 				imsg = nil // consume message
 			}
 
-		case key.Matches(msg, m.KeyMap.LineNext):
-			if m.text.AtLastLineOfInputAndView() {
+		case key.Matches(k, m.KeyMap.LineNext):
+			if m.text.AtLastLineOfInputAndView() && !m.secret() {
 				m.historyDown()
 				imsg = nil // consume message
 			}
 
-		case key.Matches(msg, m.KeyMap.ReflowLine):
+		case key.Matches(k, m.KeyMap.ReflowLine):
 			cmd = tea.Batch(cmd, m.reflowLine())
 			imsg = nil
 
-		case key.Matches(msg, m.KeyMap.ReflowAll):
+		case key.Matches(k, m.KeyMap.ReflowAll):
 			cmd = tea.Batch(cmd, m.reflowAll())
 			imsg = nil
+
+		case key.Matches(k, m.KeyMap.Undo):
+			m.text.Undo()
+			imsg = nil
+
+		case key.Matches(k, m.KeyMap.Redo):
+			m.text.Redo()
+			imsg = nil
 		}
 	}
 
 	var newCmd tea.Cmd
 	m.text, newCmd = m.text.Update(imsg)
+	if m.PromptFunc != nil && m.CurrentEditMode() != m.promptMode {
+		// Display the new mode in the prompt.
+		m.updatePrompt()
+	}
 	cmd = tea.Batch(cmd, newCmd, m.updateTextSz())
 
+	if stop && m.Err == nil && m.HistoryExpansion && !m.secret() {
+		var expandCmd tea.Cmd
+		stop, expandCmd = m.expandHistory()
+		cmd = tea.Batch(cmd, expandCmd)
+	}
 	if stop {
 		m.help.ShowAll = false
 		// Reset the search/history navigation cursor to the end.
@@ -1032,16 +1490,26 @@ func (m *Model) Reset() {
 	m.debugMode = false
 	m.showCompletions = false
 	m.completions.Blur()
+	m.cancelAsyncCompletion()
 	m.hctrl.c.valueSaved = false
 	m.hctrl.c.prevValue = ""
 	m.hctrl.c.prevCursor = 0
+	m.hctrl.c.picker = false
+	m.hctrl.c.matches = nil
+	m.yankArg = yankArgState{arg: -1}
 	m.text.CharLimit = m.CharLimit
 	m.text.MaxHeight = m.MaxHeight
 	m.text.MaxWidth = m.MaxWidth
+	m.text.MaxKillRingSize = m.MaxKillRingSize
+	m.text.UndoMemoryLimit = m.UndoMemoryLimit
+	m.text.Indent = m.Indent
+	m.text.CommentPrefix = m.CommentPrefix
+	m.keySeqPrefix = ""
 	// Width will be set by Update below on init.
 	m.text.SetHeight(1)
 	m.completions.SetHeight(1)
-	m.text.Reset()
+	m.resetText()
+	m.applyEditMode()
 	m.Focus()
 }
 
@@ -1060,14 +1528,25 @@ func (m Model) View() string {
 		buf.WriteByte('\n')
 	}
 
-	if m.showCompletions {
+	if m.showCompletions || m.completions.Loading() || m.pickerActive() {
 		buf.WriteString(m.completions.View())
 		buf.WriteByte('\n')
 	}
+	if m.currentlySearching() && len(m.hctrl.c.matches) > 0 {
+		m.text.Highlighter = m.searchHighlighter()
+	}
 	buf.WriteString(m.text.View())
 	if m.currentlySearching() {
 		buf.WriteByte('\n')
-		buf.WriteString(m.hctrl.pattern.View())
+		if ts := m.searchTimestamp(); ts != "" {
+			// Make room for the timestamp at the end of the line.
+			p := m.hctrl.pattern
+			p.Width = max(p.Width-rw.StringWidth(ts), 1)
+			buf.WriteString(p.View())
+			buf.WriteString(p.PlaceholderStyle.Render(ts))
+		} else {
+			buf.WriteString(m.hctrl.pattern.View())
+		}
 	} else {
 		buf.WriteByte('\n')
 		buf.WriteString(m.help.View(m))
@@ -1077,7 +1556,7 @@ func (m Model) View() string {
 
 // ShortHelp is part of the help.KeyMap interface.
 func (m Model) ShortHelp() []key.Binding {
-	k := m.KeyMap
+	k := m.helpKeyMap()
 	kb := []key.Binding{
 		k.MoreHelp,
 	}
@@ -1085,7 +1564,7 @@ func (m Model) ShortHelp() []key.Binding {
 		return append(kb, m.completions.ShortHelp()...)
 	}
 	return append(kb,
-		k.EndOfInput, k.Interrupt, k.SearchBackward, k.HideShowPrompt,
+		k.EndOfInput, k.Interrupt, k.AbortCompletion, k.SearchBackward, k.HideShowPrompt,
 	)
 }
 
@@ -1094,7 +1573,7 @@ func (m Model) FullHelp() [][]key.Binding {
 	if m.showCompletions {
 		return m.completions.FullHelp()
 	}
-	k := m.KeyMap
+	k := m.helpKeyMap()
 	return [][]key.Binding{
 		{
 			k.MoreHelp,
@@ -1105,6 +1584,10 @@ func (m Model) FullHelp() [][]key.Binding {
 			k.DeleteWordForward,
 			k.LineEnd,
 			k.DeleteAfterCursor,
+			k.Yank,
+			k.YankLastArg,
+			k.SetMark,
+			k.IndentRegion,
 			k.LineNext,
 			k.HistoryNext,
 			k.ReflowLine,
@@ -1119,6 +1602,9 @@ func (m Model) FullHelp() [][]key.Binding {
 			k.DeleteWordBackward,
 			k.LineStart,
 			k.DeleteBeforeCursor,
+			k.YankPop,
+			k.CopyRegion,
+			k.ToggleComment,
 			k.LinePrevious,
 			k.HistoryPrevious,
 			k.ReflowAll,
@@ -1135,7 +1621,10 @@ func (m Model) FullHelp() [][]key.Binding {
 			k.LowercaseWordForward,
 			k.UppercaseWordForward,
 			k.SearchBackward,
+			k.SearchForward,
 			k.AutoComplete,
+			k.Undo,
+			k.Redo,
 			k.ExternalEdit,
 		},
 	}
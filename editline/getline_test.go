@@ -1,13 +1,17 @@
 package editline_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
+	"unicode"
 
 	"github.com/charmbracelet/bubbles/cursor"
 	tea "github.com/charmbracelet/bubbletea"
@@ -16,6 +20,7 @@ import (
 	"github.com/knz/bubbline"
 	"github.com/knz/bubbline/computil"
 	"github.com/knz/bubbline/editline"
+	"github.com/knz/bubbline/history"
 	"github.com/knz/catwalk"
 	"github.com/muesli/termenv"
 )
@@ -51,6 +56,12 @@ func TestBubbline(t *testing.T) {
 				}
 				return nil
 			}),
+			catwalk.WithObserver("killring", func(out io.Writer, m tea.Model) error {
+				for _, e := range m.(*bubbline.Editor).GetKillRing() {
+					fmt.Fprintf(out, "%q\n", e)
+				}
+				return nil
+			}),
 			catwalk.WithObserver("err", func(out io.Writer, m tea.Model) error {
 				e := m.(*bubbline.Editor).Err
 				if e != nil {
@@ -84,8 +95,65 @@ func testCmd(m tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd,
 			"peter parker was not spiderman",
 			"this is a big world indeed",
 		})
+	case "set_timed_history":
+		ts := time.Date(2022, 8, 1, 10, 30, 0, 0, time.UTC)
+		t.SetHistoryEntries([]editline.HistoryEntry{
+			{Text: "say hello to the world", Time: ts},
+			{Text: "peter parker was not spiderman", Time: ts.Add(time.Hour)},
+			{Text: "this is a big world indeed"},
+			{Text: "select 1,\n  2", Time: ts.Add(2 * time.Hour)},
+			{Text: "say hello to the world", Time: ts.Add(3 * time.Hour)},
+		})
+	case "enable_history_picker":
+		t.HistoryPicker = true
+	case "enable_prefix_history":
+		t.FilterHistoryByPrefix = true
+	case "enable_history_expansion":
+		t.HistoryExpansion = true
+	case "enable_history_verify":
+		t.HistoryVerify = true
+	case "set_sql_tokenizer":
+		t.Tokenizer = computil.SQLWords
+	case "set_sql_history":
+		t.SetHistory([]string{
+			"select 1",
+			"insert into t values (1)",
+			"select 2",
+			"select 1",
+			"delete from t",
+			"select 3",
+		})
+	case "set_store_history":
+		t.SetHistoryStore(externalStore{history.NewMemoryStore(history.FromTexts([]string{
+			"select 1",
+			"insert into t values (1)",
+			"select 2",
+			"delete from t",
+		}))})
+	case "set_large_store_history":
+		// An old entry, followed by many recent ones.
+		h := []history.Entry{{Text: "select old"}}
+		for i := 0; i < 2000; i++ {
+			h = append(h, history.Entry{Text: fmt.Sprintf("insert %d", i)})
+		}
+		t.SetHistoryStore(externalStore{history.NewMemoryStore(h)})
+	case "show_search_timestamps":
+		t.SearchTimestampFormat = "2006-01-02 15:04"
+	case "set_search_mode":
+		switch args[0] {
+		case "glob":
+			t.SearchMode = editline.SearchGlob
+		case "substring":
+			t.SearchMode = editline.SearchSubstring
+		case "regexp":
+			t.SearchMode = editline.SearchRegexp
+		}
 	case "add_history":
 		t.AddHistoryEntry(t.Value())
+	case "set_kill_ring":
+		t.SetKillRing([]string{"first kill", "second kill", "third kill"})
+	case "limit_kill_ring_size":
+		t.MaxKillRingSize = 2
 	case "toggle_dedup_history":
 		t.DedupHistory = !t.DedupHistory
 	case "limit_history_size":
@@ -101,6 +169,12 @@ func testCmd(m tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd,
 		t.Focus()
 	case "blur":
 		t.Blur()
+	case "set_password_mode":
+		t.EchoMode = editline.EchoPassword
+	case "set_echo_none":
+		t.EchoMode = editline.EchoNone
+	case "set_echo_normal":
+		t.EchoMode = editline.EchoNormal
 	case "enable_ext_edit":
 		t.SetExternalEditorEnabled(true, "hello")
 	case "enable_debug":
@@ -124,10 +198,115 @@ func testCmd(m tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd,
 		t.AutoComplete = autocomplete1
 	case "set_autocomplete_2":
 		t.AutoComplete = autocomplete2
+	case "set_fuzzy_autocomplete":
+		t.FuzzyCompletion = true
+		t.AutoComplete = autocomplete3
+	case "set_async_autocomplete":
+		t.AsyncAutoComplete = func(_ context.Context, v [][]rune, line, col int) (string, editline.Completions) {
+			return autocomplete2(v, line, col)
+		}
+	case "set_blocking_autocomplete":
+		// The completion never finishes until canceled.
+		t.AsyncAutoComplete = func(ctx context.Context, v [][]rune, line, col int) (string, editline.Completions) {
+			<-ctx.Done()
+			return autocomplete2(v, line, col)
+		}
+	case "tab_then_type":
+		// Start a completion and type a key before the completion
+		// results are delivered. The results must be discarded.
+		_, cmd := t.Update(tea.KeyMsg{Type: tea.KeyTab})
+		t.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(args[0])})
+		return true, t, cmd, nil
+	case "write_output":
+		// Write the words without a newline.
+		fmt.Fprint(t, strings.Join(args, " ")+" ")
+	case "write_line":
+		t.Printf("%s", strings.Join(args, " "))
+	case "flush_output":
+		// Simulate the start of a prompt.
+		return true, t, t.Init(), nil
+	case "resize_editor":
+		w, _ := strconv.Atoi(args[0])
+		h, _ := strconv.Atoi(args[1])
+		t.Resize(w, h)
+		// Simulate the start of a prompt.
+		return true, t, t.Init(), nil
+	case "set_terminal_type":
+		t.SetTerminalType(args[0])
 	case "show_cursor":
 		t.CursorMode = cursor.CursorStatic
 	case "hide_cursor":
 		t.CursorMode = cursor.CursorHide
+	case "load_inputrc":
+		rc, err := editline.ParseInputrc(strings.NewReader(`
+# Move by words with C-t, like C-f/C-b by characters.
+"\C-t": backward-word
+Control-v: forward-word
+$if mode=vi
+"\C-t": backward-char
+$endif
+`), "test")
+		if err != nil {
+			return false, m, nil, err
+		}
+		if err := t.ApplyInputrc(rc); err != nil {
+			return false, m, nil, err
+		}
+	case "set_vi_mode":
+		t.SetEditMode(editline.ViInsertMode)
+	case "set_emacs_mode":
+		t.SetEditMode(editline.EmacsMode)
+	case "set_mode_prompt":
+		t.PromptFunc = func(line int, mode editline.EditMode) string {
+			if line > 0 {
+				return "     "
+			}
+			switch mode {
+			case editline.ViInsertMode:
+				return "[I]> "
+			case editline.ViNormalMode:
+				return "[N]> "
+			}
+			return "> "
+		}
+	case "show_line_numbers":
+		t.ShowLineNumbers = true
+	case "set_highlighter":
+		// Highlight digits in bold and words in single quotes in red.
+		t.Highlighter = func(v [][]rune) [][]editline.StyleSpan {
+			res := make([][]editline.StyleSpan, len(v))
+			for i, line := range v {
+				for j := 0; j < len(line); j++ {
+					switch {
+					case unicode.IsDigit(line[j]):
+						end := j + 1
+						for end < len(line) && unicode.IsDigit(line[end]) {
+							end++
+						}
+						res[i] = append(res[i], editline.StyleSpan{Start: j, End: end, Style: lipgloss.NewStyle().Bold(true)})
+						j = end - 1
+					case line[j] == '\'':
+						end := j + 1
+						for end < len(line) && line[end] != '\'' {
+							end++
+						}
+						end = min(end+1, len(line))
+						res[i] = append(res[i], editline.StyleSpan{Start: j, End: end, Style: lipgloss.NewStyle().Foreground(lipgloss.Color("1"))})
+						j = end - 1
+					}
+				}
+			}
+			return res
+		}
+	case "enable_autosuggest":
+		t.AutoSuggest = true
+	case "set_suggest_fn":
+		t.Suggest = func(v [][]rune) string {
+			if string(v[len(v)-1]) == "sel" {
+				return "ect * from t"
+			}
+			return ""
+		}
 	case "limit_max_width":
 		t.MaxWidth = 10
 	case "limit_max_height":
@@ -138,6 +317,13 @@ func testCmd(m tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd,
 	return true, t, nil, nil
 }
 
+// externalStore is a history store that the editor does not recognize
+// as an in-memory store, so that the history is only accessed through
+// the history.Store interface.
+type externalStore struct {
+	*history.MemoryStore
+}
+
 func autocomplete1(v [][]rune, line, col int) (msg string, completions editline.Completions) {
 	// Detect the word under the cursor.
 	word, wstart, wend := computil.FindWord(v, line, col)
@@ -184,6 +370,12 @@ func autocomplete2(v [][]rune, line, col int) (msg string, completions editline.
 	return msg, editline.SimpleWordsCompletion(candidates, "names", col, wstart, wend)
 }
 
+// autocomplete3 returns all the names, for use with fuzzy matching.
+func autocomplete3(v [][]rune, line, col int) (msg string, completions editline.Completions) {
+	_, wstart, wend := computil.FindWord(v, line, col)
+	return "", editline.SimpleWordsCompletion(names, "names", col, wstart, wend)
+}
+
 var names = func() []string {
 	s := []string{"Andrew", "Anthony", "Arthur", "Brian", "Carl",
 		"Charles", "Christopher", "Daniel", "David", "Dennis", "Donald",
@@ -0,0 +1,20 @@
+package editline
+
+import "github.com/knz/bubbline/editline/internal/textarea"
+
+// StyleSpan is a range of characters in one line of the input that
+// is rendered with a specific style. Start and End are positions in
+// runes from the beginning of the line; End is exclusive.
+type StyleSpan = textarea.StyleSpan
+
+// HighlightFn is the type of the Highlighter callback. It is called
+// with the entire input every time the input is rendered, and
+// returns the spans to apply to each line: the i-th entry of the
+// result applies to the i-th line. The result can be shorter than the
+// input if the last lines have no spans. When spans overlap, the
+// later ones take precedence.
+//
+// The styles of the spans are combined with the style of the line,
+// for example the cursor line style, and the selection style is
+// applied on top.
+type HighlightFn = textarea.HighlightFn
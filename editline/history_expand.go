@@ -0,0 +1,27 @@
+package editline
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/knz/bubbline/history"
+)
+
+// expandHistory performs history expansion on the input when it is
+// complete, see HistoryExpansion. It returns false if the input should
+// not be completed: when the expansion fails, or with HistoryVerify
+// when the expanded input is displayed for review.
+func (m *Model) expandHistory() (complete bool, cmd tea.Cmd) {
+	expanded, changed, err := history.ExpandStore(m.text.Value(), m.history)
+	if err != nil {
+		// Keep the input for correction.
+		return false, tea.Println(err.Error())
+	}
+	if !changed {
+		return true, nil
+	}
+	m.text.Checkpoint()
+	m.text.SetValue(expanded)
+	if m.HistoryVerify {
+		return false, m.updateTextSz()
+	}
+	return true, m.updateTextSz()
+}
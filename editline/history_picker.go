@@ -0,0 +1,195 @@
+package editline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/knz/bubbline/complete"
+	rw "github.com/mattn/go-runewidth"
+	"github.com/muesli/reflow/truncate"
+)
+
+// defaultPickerTimestampFormat is used to display the timestamps in
+// the history picker when SearchTimestampFormat is not set.
+const defaultPickerTimestampFormat = "2006-01-02 15:04"
+
+// historyValues presents the history entries in the history picker,
+// most recent first.
+type historyValues struct {
+	entries []historyCandidate
+}
+
+var _ complete.Values = (*historyValues)(nil)
+
+func (h *historyValues) NumCategories() int                   { return 1 }
+func (h *historyValues) CategoryTitle(int) string             { return "history" }
+func (h *historyValues) NumEntries(int) int                   { return len(h.entries) }
+func (h *historyValues) Entry(_, entryIdx int) complete.Entry { return h.entries[entryIdx] }
+
+// historyCandidate is one entry in the history picker.
+type historyCandidate struct {
+	// text is the history entry.
+	text string
+	// title is the text displayed in the list: the first line of
+	// the entry, followed by the other lines separated by a newline
+	// symbol.
+	title string
+	// desc is the timestamp and size of the entry, if any.
+	desc string
+}
+
+func (c historyCandidate) Title() string       { return c.title }
+func (c historyCandidate) Description() string { return c.desc }
+
+// maxPickerEntries is the number of most recent history entries
+// listed by the history picker. This bounds the size of the list, which
+// is filtered upon every key press.
+const maxPickerEntries = 1000
+
+// newHistoryValues prepares the history entries for display in the
+// history picker, among the maxPickerEntries most recent ones.
+// Duplicate entries are only listed once, at the position of the most
+// recent one. The titles are truncated to the given width.
+func (m *Model) newHistoryValues(width int) *historyValues {
+	layout := m.SearchTimestampFormat
+	if layout == "" {
+		layout = defaultPickerTimestampFormat
+	}
+	n := m.history.Len()
+	first := max(n-maxPickerEntries, 0)
+	seen := make(map[string]struct{}, n-first)
+	h := &historyValues{}
+	for i := n - 1; i >= first; i-- {
+		e := m.historyAt(i)
+		if _, ok := seen[e.Text]; ok {
+			continue
+		}
+		seen[e.Text] = struct{}{}
+		// The newline symbol is a single rune, so that the
+		// positions of the characters matched by the pattern are the
+		// same in the title and in the entry.
+		title := strings.ReplaceAll(e.Text, "\n", "↵")
+		if rw.StringWidth(title) > width {
+			title = truncate.StringWithTail(title, uint(width), "…")
+		}
+		var desc []string
+		if !e.Time.IsZero() {
+			desc = append(desc, e.Time.Format(layout))
+		}
+		if n := strings.Count(e.Text, "\n") + 1; n > 1 {
+			desc = append(desc, fmt.Sprintf("%d lines", n))
+		}
+		h.entries = append(h.entries, historyCandidate{
+			text:  e.Text,
+			title: title,
+			desc:  strings.Join(desc, ", "),
+		})
+	}
+	return h
+}
+
+// pickerActive returns true while the history picker is displayed.
+func (m *Model) pickerActive() bool {
+	return m.hctrl.c.searching && m.hctrl.c.picker
+}
+
+// startPicker switches the history search to the picker, keeping the
+// search pattern.
+func (m *Model) startPicker() tea.Cmd {
+	m.hctrl.c.picker = true
+	m.hctrl.c.forward = false
+	m.completions.SetFilteringEnabled(false)
+	m.completions.SetValues(m.newHistoryValues(max(m.maxWidth-3, 10)))
+	m.completions.SetPattern(m.hctrl.pattern.Value())
+	m.completions.Focus()
+	return tea.Batch(m.previewPicker(), m.updateTextSz())
+}
+
+// stopPicker hides the history picker.
+func (m *Model) stopPicker() tea.Cmd {
+	if !m.hctrl.c.picker {
+		return nil
+	}
+	m.hctrl.c.picker = false
+	m.completions.Blur()
+	return m.updateTextSz()
+}
+
+// previewPicker displays the entry selected in the history picker in
+// the editor, or the input from before the search if there is none.
+func (m *Model) previewPicker() tea.Cmd {
+	c, ok := m.completions.SelectedEntry().(historyCandidate)
+	if !ok {
+		m.hctrl.pattern.Prompt = m.SearchPromptNotFound
+		m.hctrl.c.matches = nil
+		return m.updateValue(m.hctrl.c.prevValue, m.hctrl.c.prevCursor)
+	}
+	m.hctrl.pattern.Prompt = m.SearchPrompt
+	m.setPickerMatches(c.text)
+	return m.updateValue(c.text, len(c.text))
+}
+
+// handlePicker processes keys while the history picker is displayed.
+func (m *Model) handlePicker(imsg tea.Msg) (stillSearching bool, restMsg tea.Msg, cmd tea.Cmd) {
+	msg, isKey := asKey(imsg)
+	if !isKey {
+		return true, nil, nil
+	}
+	_, hasSelection := m.completions.SelectedEntry().(historyCandidate)
+	switch {
+	case key.Matches(msg, m.KeyMap.EndOfInput) && m.hctrl.pattern.Position() == 0,
+		key.Matches(msg, m.KeyMap.AbortSearch, m.KeyMap.Interrupt):
+		cmd = m.cancelHistorySearch()
+		return false, nil, tea.Batch(cmd, m.stopPicker())
+
+	case key.Matches(msg, m.KeyMap.ToggleSearchPicker, m.KeyMap.SearchBackward):
+		// Continue with the classic search, starting from the input
+		// prior to the search.
+		cmd = tea.Batch(m.stopPicker(), m.updateValue(m.hctrl.c.prevValue, m.hctrl.c.prevCursor))
+		m.hctrl.pattern.Prompt = m.SearchPrompt
+		m.hctrl.c.prevPattern = ""
+		m.hctrl.c.matches = nil
+		return true, nil, tea.Batch(cmd, m.incrementalSearch(false /* nextMatch */))
+
+	case key.Matches(msg, m.KeyMap.AutoComplete):
+		// Keep the entry for further editing. If there is no entry
+		// selected, the input prior to the search is displayed
+		// already.
+		cmd = m.stopPicker()
+		m.acceptSearch()
+		return false, nil, cmd
+
+	case key.Matches(msg, m.KeyMap.InsertNewline, m.KeyMap.AlwaysComplete):
+		if !hasSelection {
+			// Nothing to accept.
+			return true, nil, nil
+		}
+		// Accept the entry, and let the editor complete the input.
+		cmd = m.stopPicker()
+		m.acceptSearch()
+		return false, imsg, cmd
+
+	case key.Matches(msg, m.completions.KeyMap.CursorUp, m.completions.KeyMap.CursorDown,
+		m.completions.KeyMap.NextPage, m.completions.KeyMap.PrevPage):
+		_, cmd = m.completions.Update(msg)
+		return true, nil, tea.Batch(cmd, m.previewPicker())
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok && !msg.Alt && (msg.Type == tea.KeySpace ||
+		msg.Type == tea.KeyBackspace ||
+		msg.Type == tea.KeyCtrlH ||
+		msg.Type == tea.KeyRunes) {
+		// Update the pattern and the matching entries.
+		m.hctrl.pattern, cmd = m.hctrl.pattern.Update(msg)
+		m.completions.SetPattern(m.hctrl.pattern.Value())
+		return true, nil, tea.Batch(cmd, m.previewPicker(), m.updateTextSz())
+	}
+
+	// Any other key combo: accept current result then let the
+	// editor do its job.
+	cmd = m.stopPicker()
+	m.acceptSearch()
+	return false, imsg, cmd
+}
@@ -0,0 +1,108 @@
+package editline
+
+import (
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/knz/bubbline/history"
+)
+
+// prefixNav is the state of a history navigation session filtered by
+// prefix, see FilterHistoryByPrefix.
+type prefixNav struct {
+	// prefix is the text before the cursor when the session started.
+	prefix string
+	// value is the entry displayed last. If the input differs, it was
+	// edited and the next navigation starts a new session.
+	value string
+	// visited is the stack of history positions to return to with
+	// historyDown. The first one is the position where the session
+	// started.
+	visited []int
+	// seen is the set of entries displayed during the session, to skip
+	// duplicates.
+	seen map[string]struct{}
+}
+
+// textBeforeCursor returns the text of the input before the cursor.
+func (m *Model) textBeforeCursor() string {
+	lines := m.text.ValueRunes()
+	row, col := m.text.Line(), m.text.CursorPos()
+	var buf strings.Builder
+	for _, line := range lines[:row] {
+		buf.WriteString(string(line))
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(string(lines[row][:col]))
+	return buf.String()
+}
+
+// startPrefixNav starts a history navigation session filtered by the
+// text before the cursor, from the current history position.
+func (m *Model) startPrefixNav() {
+	m.saveValue()
+	value := m.text.Value()
+	m.hctrl.nav = prefixNav{
+		prefix: m.textBeforeCursor(),
+		value:  value,
+		// The input itself is not recalled again.
+		seen: map[string]struct{}{value: {}},
+	}
+}
+
+// prefixHistoryUp recalls the previous history entry that starts with
+// the prefix of the navigation session, skipping duplicates.
+func (m *Model) prefixHistoryUp() (cmd tea.Cmd) {
+	if !m.hctrl.c.valueSaved || m.text.Value() != m.hctrl.nav.value {
+		// New navigation, or the entry was edited since.
+		m.startPrefixNav()
+	}
+	nav := &m.hctrl.nav
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(nav.prefix))
+	for i := m.hctrl.c.cursor - 1; i >= 0; i-- {
+		if i, _ = m.history.Search(re, i, history.Backward); i < 0 {
+			break
+		}
+		entry := m.historyAt(i).Text
+		if _, ok := nav.seen[entry]; ok {
+			continue
+		}
+		nav.seen[entry] = struct{}{}
+		nav.visited = append(nav.visited, m.hctrl.c.cursor)
+		nav.value = entry
+		m.text.Checkpoint()
+		m.hctrl.c.cursor = i
+		// Keep the cursor at the end of the prefix, so that the
+		// following navigation uses the same prefix.
+		return m.updateValue(entry, len(nav.prefix))
+	}
+	return cmd
+}
+
+// prefixHistoryDown returns to the entry displayed before the last
+// prefixHistoryUp, or to the input prior to the navigation.
+func (m *Model) prefixHistoryDown() (cmd tea.Cmd) {
+	nav := &m.hctrl.nav
+	if !m.hctrl.c.valueSaved || m.text.Value() != nav.value {
+		// Nothing recalled, or the entry was edited since.
+		return cmd
+	}
+	n := len(nav.visited)
+	if n == 0 {
+		return cmd
+	}
+	m.text.Checkpoint()
+	pos := nav.visited[n-1]
+	nav.visited = nav.visited[:n-1]
+	if n == 1 {
+		// Back to the input prior to the navigation, at the history
+		// position where the navigation started.
+		cmd = m.restoreValue()
+		m.hctrl.c.cursor = pos
+		return cmd
+	}
+	m.hctrl.c.cursor = pos
+	nav.value = m.historyAt(m.hctrl.c.cursor).Text
+	return m.updateValue(nav.value, len(nav.prefix))
+}
@@ -0,0 +1,24 @@
+package editline
+
+import "strings"
+
+// FilterHistoryEntry applies the privacy settings to an entry about
+// to be added to the history: the text is redacted with
+// HistoryRedact, and the entry is marked as ephemeral if it is
+// excluded by HistoryIgnoreSpace or HistoryIgnorePatterns, so that it
+// is not saved to the history file.
+func (m *Model) FilterHistoryEntry(e HistoryEntry) HistoryEntry {
+	if m.HistoryIgnoreSpace && strings.HasPrefix(e.Text, " ") {
+		e.Ephemeral = true
+	}
+	for _, re := range m.HistoryIgnorePatterns {
+		if re.MatchString(e.Text) {
+			e.Ephemeral = true
+			break
+		}
+	}
+	if m.HistoryRedact != nil {
+		e.Text = m.HistoryRedact(e.Text)
+	}
+	return e
+}
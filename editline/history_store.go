@@ -0,0 +1,45 @@
+package editline
+
+import "github.com/knz/bubbline/history"
+
+// SetHistoryStore makes the history navigation and search use the
+// given store, for example a history.FileStore to page through a very
+// large history without loading it in memory. The entries added
+// subsequently are appended to the store; errors are ignored.
+//
+// MaxHistorySize and EraseDupHistory only apply to a
+// history.MemoryStore, as the other stores cannot remove entries:
+// they are ignored by AppendHistoryEntry, and reported as an error by
+// the AppendHistory method of bubbline.Editor. SetHistory and
+// SetHistoryEntries replace the store by a new history.MemoryStore.
+func (m *Model) SetHistoryStore(s history.Store) {
+	m.history = s
+	m.checkHistoryEnabled()
+	m.resetNavCursor()
+}
+
+// HistoryStore returns the store used for the history navigation
+// list. This is a history.MemoryStore, unless another store was
+// configured with SetHistoryStore.
+func (m *Model) HistoryStore() history.Store {
+	return m.history
+}
+
+// historyAt returns the history entry at position i. An entry that
+// cannot be read from the store is considered to be empty.
+func (m *Model) historyAt(i int) HistoryEntry {
+	e, _ := m.history.At(i)
+	return e
+}
+
+// historyEntries returns all the entries of the history.
+func (m *Model) historyEntries() []HistoryEntry {
+	if s, ok := m.history.(*history.MemoryStore); ok {
+		return s.Entries()
+	}
+	h := make([]HistoryEntry, m.history.Len())
+	for i := range h {
+		h[i] = m.historyAt(i)
+	}
+	return h
+}
@@ -0,0 +1,342 @@
+package editline_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/knz/bubbline"
+	"github.com/knz/bubbline/history"
+)
+
+func TestHistoryMetadata(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := bubbline.New()
+	m.SetHistoryFormat(history.FormatJSONL)
+	m.SetHistorySessionID("s1")
+	m.SetAutoSaveHistory(fname, true)
+	if err := m.AddHistory("hello"); err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2022, 8, 1, 10, 30, 0, 0, time.UTC)
+	if err := m.AppendHistory(history.Entry{Text: "world", Time: ts, Status: "error", Duration: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The metadata survives a round trip through the history file.
+	m2 := bubbline.New()
+	if err := m2.LoadHistory(fname); err != nil {
+		t.Fatal(err)
+	}
+	h := m2.GetHistoryEntries()
+	if len(h) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", h)
+	}
+	if h[0].Text != "hello" || h[0].SessionID != "s1" || h[0].Dir != wd || h[0].Time.IsZero() {
+		t.Errorf("unexpected first entry: %+v", h[0])
+	}
+	exp := history.Entry{Text: "world", Time: ts, SessionID: "s1", Dir: wd, Status: "error", Duration: time.Second}
+	if h[1] != exp {
+		t.Errorf("expected %+v, got %+v", exp, h[1])
+	}
+
+	// The file keeps the JSON lines format, and thus the metadata, even
+	// when saved by an editor configured with the libedit format.
+	m2.SetAutoSaveHistory(fname, false)
+	if err := m2.SaveHistory(); err != nil {
+		t.Fatal(err)
+	}
+	h2, err := history.LoadHistory(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h2) != 2 || h2[0] != "hello" || h2[1] != "world" {
+		t.Errorf("unexpected history: %q", h2)
+	}
+	if err := m.LoadHistory(fname); err != nil {
+		t.Fatal(err)
+	}
+	if h := m.GetHistoryEntries(); len(h) != 2 || h[1] != exp {
+		t.Errorf("unexpected history: %+v", h)
+	}
+}
+
+func TestHistorySharing(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	m1, m2 := bubbline.New(), bubbline.New()
+	for _, m := range []*bubbline.Editor{m1, m2} {
+		m.MaxHistorySize = 3
+		m.SetAutoSaveHistory(fname, true)
+	}
+
+	add := func(m *bubbline.Editor, line string, exp ...string) {
+		t.Helper()
+		if err := m.AddHistory(line); err != nil {
+			t.Fatal(err)
+		}
+		if h := m.GetHistory(); !reflect.DeepEqual(h, exp) {
+			t.Errorf("add %q: expected %q, got %q", line, exp, h)
+		}
+	}
+	// The entries added by each editor are merged into the history of
+	// the other.
+	add(m1, "a", "a")
+	add(m2, "b", "a", "b")
+	add(m1, "c", "a", "b", "c")
+	add(m2, "d", "b", "c", "d")
+	add(m2, "e", "c", "d", "e")
+	add(m2, "f", "d", "e", "f")
+	// The file is compacted when it exceeds twice the maximum size.
+	add(m1, "g", "e", "f", "g")
+	h, err := history.LoadHistory(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"e", "f", "g"}; !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected %q, got %q", exp, h)
+	}
+	// The other editor notices that the file was compacted.
+	add(m2, "h", "f", "g", "h")
+}
+
+func TestHistoryPrivacy(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	m := bubbline.New()
+	m.SetAutoSaveHistory(fname, true)
+	m.HistoryIgnoreSpace = true
+	m.HistoryIgnorePatterns = []*regexp.Regexp{regexp.MustCompile(`^\\password`)}
+	passwordRe := regexp.MustCompile(`(?i)(password\s+)'[^']*'`)
+	m.HistoryRedact = func(entry string) string {
+		return passwordRe.ReplaceAllString(entry, "$1'***'")
+	}
+	for _, line := range []string{
+		"select 1",
+		" select 2",
+		"\\password alice",
+		"alter user alice password 'hunter2'",
+	} {
+		if err := m.AddHistory(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The ignored entries remain in the history of the current session.
+	all := []string{"select 1", " select 2", "\\password alice", "alter user alice password '***'"}
+	if h := m.GetHistory(); !reflect.DeepEqual(h, all) {
+		t.Errorf("expected %q, got %q", all, h)
+	}
+	// But they are not saved.
+	saved := []string{"select 1", "alter user alice password '***'"}
+	h, err := history.LoadHistory(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h, saved) {
+		t.Errorf("expected %q, got %q", saved, h)
+	}
+
+	// Also not upon compaction.
+	if err := m.SaveHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if h := m.GetHistory(); !reflect.DeepEqual(h, all) {
+		t.Errorf("expected %q, got %q", all, h)
+	}
+	h, err = history.LoadHistory(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h, saved) {
+		t.Errorf("expected %q, got %q", saved, h)
+	}
+}
+
+func TestHistoryEraseDups(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	m := bubbline.New()
+	m.EraseDupHistory = true
+	m.DedupWhitespace = true
+	m.MaxHistorySize = 3
+	m.SetAutoSaveHistory(fname, true)
+
+	add := func(line string, exp ...string) {
+		t.Helper()
+		if err := m.AddHistory(line); err != nil {
+			t.Fatal(err)
+		}
+		if h := m.GetHistory(); !reflect.DeepEqual(h, exp) {
+			t.Errorf("add %q: expected %q, got %q", line, exp, h)
+		}
+		// The file ends with the same entries, and has no duplicates.
+		// It may contain older entries until it is compacted.
+		h, err := history.LoadEntries(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history.Dedup(h, true)) != len(h) || len(h) < len(exp) ||
+			!reflect.DeepEqual(history.Texts(h[len(h)-len(exp):]), exp) {
+			t.Errorf("add %q: expected file ending with %q, got %q", line, exp, history.Texts(h))
+		}
+	}
+	add("a", "a")
+	add("b", "a", "b")
+	add("a", "b", "a")
+	add("c", "b", "a", "c")
+	// The duplicate is removed before truncation.
+	add("b", "a", "c", "b")
+	add("d", "c", "b", "d")
+	// Whitespace differences are ignored.
+	add("  c ", "b", "d", "  c ")
+
+	// The duplicates are removed when setting the history.
+	m.SetHistory([]string{"x", "y", "x", "z", "y"})
+	if exp, h := []string{"x", "z", "y"}, m.GetHistory(); !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected %q, got %q", exp, h)
+	}
+
+}
+
+func TestHistoryEraseDupsEphemeral(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	m := bubbline.New()
+	m.EraseDupHistory = true
+	m.DedupWhitespace = true
+	m.HistoryIgnoreSpace = true
+	m.SetAutoSaveHistory(fname, true)
+	for _, line := range []string{"select 1", "select 2", " select 1"} {
+		if err := m.AddHistory(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The ephemeral entry does not erase its persistent duplicate,
+	// which remains in the file.
+	if exp, h := []string{"select 1", "select 2", " select 1"}, m.GetHistory(); !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected %q, got %q", exp, h)
+	}
+	if err := m.SaveHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := history.LoadHistory(fname); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"select 1", "select 2"}; !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected file %q, got %q", exp, h)
+	}
+}
+
+func TestHistoryNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	sqlFile, yesNoFile := filepath.Join(dir, "sql"), filepath.Join(dir, "yesno")
+	m := bubbline.New()
+	m.SetAutoSaveHistory(sqlFile, true)
+
+	add := func(line string, exp ...string) {
+		t.Helper()
+		if err := m.AddHistory(line); err != nil {
+			t.Fatal(err)
+		}
+		if h := m.GetHistory(); !reflect.DeepEqual(h, exp) {
+			t.Errorf("add %q: expected %q, got %q", line, exp, h)
+		}
+	}
+	add("select 1", "select 1")
+	add("select 2", "select 1", "select 2")
+
+	// A new namespace starts empty, with its own settings.
+	m.SetHistoryNamespace("yesno")
+	if ns := m.HistoryNamespace(); ns != "yesno" {
+		t.Errorf("expected namespace %q, got %q", "yesno", ns)
+	}
+	if h := m.GetHistory(); len(h) != 0 {
+		t.Errorf("expected empty history, got %q", h)
+	}
+	m.SetAutoSaveHistory(yesNoFile, true)
+	m.MaxHistorySize = 2
+	m.EraseDupHistory = true
+	add("yes", "yes")
+	add("no", "yes", "no")
+	add("yes", "no", "yes")
+	add("maybe", "yes", "maybe")
+
+	// Switching back restores the history and the settings.
+	m.SetHistoryNamespace("")
+	add("select 3", "select 1", "select 2", "select 3")
+	if m.MaxHistorySize != 0 || m.EraseDupHistory {
+		t.Errorf("unexpected settings: max %d, erasedups %v", m.MaxHistorySize, m.EraseDupHistory)
+	}
+	m.SetHistoryNamespace("yesno")
+	add("no", "maybe", "no")
+
+	// Each namespace is saved to its own file.
+	for fname, exp := range map[string][]string{
+		sqlFile: {"select 1", "select 2", "select 3"},
+		// The first "yes" was erased upon compaction; the first "no"
+		// was truncated from memory, but not from the file yet.
+		yesNoFile: {"no", "yes", "maybe", "no"},
+	} {
+		h, err := history.LoadHistory(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(h, exp) {
+			t.Errorf("%s: expected %q, got %q", filepath.Base(fname), exp, h)
+		}
+	}
+}
+
+func TestHistoryStore(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	s, err := history.OpenFileStore(fname, history.FormatJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := bubbline.New()
+	m.SetHistoryStore(s)
+	for _, line := range []string{"select 1", "select 2", "select 2"} {
+		if err := m.AddHistory(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exp := []string{"select 1", "select 2"}
+	if h := m.GetHistory(); !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected %q, got %q", exp, h)
+	}
+	// The entries are saved by the store.
+	h, err := history.LoadEntries(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(history.Texts(h), exp) || h[0].SessionID == "" {
+		t.Errorf("unexpected history: %+v", h)
+	}
+
+	// The options that remove entries are not supported.
+	m.EraseDupHistory = true
+	if err := m.AddHistory("select 1"); err != bubbline.ErrUnsupportedHistoryOption {
+		t.Errorf("expected ErrUnsupportedHistoryOption with EraseDupHistory, got %v", err)
+	}
+	m.EraseDupHistory = false
+	m.MaxHistorySize = 1
+	if err := m.AddHistory("select 1"); err != bubbline.ErrUnsupportedHistoryOption {
+		t.Errorf("expected ErrUnsupportedHistoryOption with MaxHistorySize, got %v", err)
+	}
+	m.MaxHistorySize = 0
+	if h := m.GetHistory(); !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected %q, got %q", exp, h)
+	}
+
+	// The errors of the store are reported.
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddHistory("select 3"); err == nil {
+		t.Error("expected error after closing the store")
+	}
+}
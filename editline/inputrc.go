@@ -0,0 +1,703 @@
+package editline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ReadlineFunctions maps the names of readline functions, as used in
+// inputrc files, to the names of the corresponding key bindings in
+// KeyMap. Applications can add entries to this map to support more
+// function names.
+//
+// In inputrc files, the KeyMap binding names can also be used directly
+// as function names, e.g. "\C-x\C-b": InputBegin.
+var ReadlineFunctions = map[string]string{
+	"forward-char":             "CharacterForward",
+	"backward-char":            "CharacterBackward",
+	"forward-word":             "WordForward",
+	"backward-word":            "WordBackward",
+	"beginning-of-line":        "LineStart",
+	"end-of-line":              "LineEnd",
+	"previous-history":         "HistoryPrevious",
+	"next-history":             "HistoryNext",
+	"beginning-of-buffer":      "InputBegin",
+	"end-of-buffer":            "InputEnd",
+	"accept-line":              "InsertNewline",
+	"backward-delete-char":     "DeleteCharacterBackward",
+	"delete-char":              "DeleteCharacterForward",
+	"kill-line":                "DeleteAfterCursor",
+	"backward-kill-line":       "DeleteBeforeCursor",
+	"unix-line-discard":        "DeleteBeforeCursor",
+	"kill-word":                "DeleteWordForward",
+	"backward-kill-word":       "DeleteWordBackward",
+	"unix-word-rubout":         "DeleteWordBackward",
+	"transpose-chars":          "TransposeCharacterBackward",
+	"upcase-word":              "UppercaseWordForward",
+	"downcase-word":            "LowercaseWordForward",
+	"capitalize-word":          "CapitalizeWordForward",
+	"overwrite-mode":           "ToggleOverwriteMode",
+	"yank":                     "Yank",
+	"yank-pop":                 "YankPop",
+	"yank-last-arg":            "YankLastArg",
+	"digit-argument":           "DigitArgument",
+	"set-mark":                 "SetMark",
+	"kill-region":              "KillRegion",
+	"copy-region-as-kill":      "CopyRegion",
+	"insert-comment":           "ToggleComment",
+	"complete":                 "AutoComplete",
+	"clear-screen":             "Refresh",
+	"redraw-current-line":      "Refresh",
+	"reverse-search-history":   "SearchBackward",
+	"forward-search-history":   "SearchForward",
+	"abort":                    "AbortSearch",
+	"undo":                     "Undo",
+	"end-of-file":              "EndOfInput",
+	"edit-and-execute-command": "ExternalEdit",
+	"edit-and-execute":         "ExternalEdit",
+}
+
+// Inputrc is the result of parsing an inputrc configuration file.
+type Inputrc struct {
+	// Bindings is the list of key bindings, in the order they were
+	// defined.
+	Bindings []InputrcBinding
+
+	// Variables is the set of variables defined with "set".
+	Variables map[string]string
+}
+
+// InputrcBinding is one key binding in an inputrc file.
+type InputrcBinding struct {
+	// Keys is the key sequence, in the notation used by key.Binding,
+	// e.g. "ctrl+x ctrl+e".
+	Keys string
+	// Function is the readline function name.
+	Function string
+	// Binding is the name of the key binding in KeyMap.
+	Binding string
+}
+
+// LoadInputrc reads and parses an inputrc configuration file. If path
+// is empty, the file named by the INPUTRC environment variable is
+// used, or ~/.inputrc if that is not set either.
+//
+// See ParseInputrc for the meaning of app and the supported syntax.
+func LoadInputrc(path, app string) (*Inputrc, error) {
+	if path == "" {
+		path = os.Getenv("INPUTRC")
+	}
+	if path == "" {
+		path = "~/.inputrc"
+	}
+	p := newInputrcParser(app)
+	if err := p.include(path); err != nil {
+		return nil, err
+	}
+	return p.rc, errors.Join(p.errs...)
+}
+
+// ParseInputrc parses a configuration in the format of readline's
+// inputrc files. The following subset of the syntax is supported:
+//
+//   - key bindings to function names, with the key specified either as
+//     a quoted key sequence ("\C-x\C-e": edit-and-execute-command) or
+//     as a key name (Control-u: unix-line-discard). The function names
+//     are looked up in ReadlineFunctions. Macros are not supported.
+//   - variable settings (set editing-mode vi). Bindings defined while
+//     the vi-command keymap is selected are ignored.
+//   - conditional blocks with $if, $else and $endif. The conditions
+//     mode=..., term=... and the application name are supported. The
+//     application name is specified with the app argument.
+//   - $include directives.
+//
+// Errors do not stop the parsing: the returned configuration contains
+// all the valid directives, and the returned error describes the
+// invalid ones.
+func ParseInputrc(r io.Reader, app string) (*Inputrc, error) {
+	p := newInputrcParser(app)
+	p.parse("inputrc", r)
+	return p.rc, errors.Join(p.errs...)
+}
+
+// maxInputrcIncludeDepth limits the nesting of $include directives.
+const maxInputrcIncludeDepth = 10
+
+func newInputrcParser(app string) *inputrcParser {
+	return &inputrcParser{
+		rc:   &Inputrc{Variables: map[string]string{}},
+		app:  app,
+		term: os.Getenv("TERM"),
+		mode: EmacsMode,
+	}
+}
+
+type inputrcParser struct {
+	rc   *Inputrc
+	errs []error
+
+	app  string
+	term string
+
+	// mode is the editing mode, used to evaluate $if mode=... .
+	mode EditMode
+	// viCommand is true while the vi-command keymap is selected.
+	viCommand bool
+
+	// conds is the stack of conditions of the enclosing $if blocks.
+	conds []bool
+	depth int
+}
+
+func (p *inputrcParser) errorf(name string, line int, format string, args ...interface{}) {
+	p.errs = append(p.errs, fmt.Errorf("%s:%d: %s", name, line, fmt.Sprintf(format, args...)))
+}
+
+// include parses the file at the specified path.
+func (p *inputrcParser) include(path string) error {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	p.parse(path, f)
+	return nil
+}
+
+// active returns true if the directives at the current position are
+// not disabled by an $if block.
+func (p *inputrcParser) active() bool {
+	for _, c := range p.conds {
+		if !c {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *inputrcParser) parse(name string, r io.Reader) {
+	p.depth++
+	defer func() { p.depth-- }()
+	nconds := len(p.conds)
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '$' {
+			p.directive(name, lineNum, line)
+			continue
+		}
+		if !p.active() {
+			continue
+		}
+		if word, rest := splitWord(line); word == "set" {
+			p.set(name, lineNum, rest)
+			continue
+		}
+		p.binding(name, lineNum, line)
+	}
+	if err := scanner.Err(); err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: %v", name, err))
+	}
+	if len(p.conds) > nconds {
+		p.errorf(name, lineNum, "missing $endif")
+		p.conds = p.conds[:nconds]
+	}
+}
+
+// directive processes a line starting with '$'.
+func (p *inputrcParser) directive(name string, lineNum int, line string) {
+	word, rest := splitWord(line)
+	switch word {
+	case "$if":
+		p.conds = append(p.conds, p.eval(rest))
+	case "$else":
+		if len(p.conds) == 0 {
+			p.errorf(name, lineNum, "$else without $if")
+			return
+		}
+		p.conds[len(p.conds)-1] = !p.conds[len(p.conds)-1]
+	case "$endif":
+		if len(p.conds) == 0 {
+			p.errorf(name, lineNum, "$endif without $if")
+			return
+		}
+		p.conds = p.conds[:len(p.conds)-1]
+	case "$include":
+		if !p.active() {
+			return
+		}
+		if p.depth >= maxInputrcIncludeDepth {
+			p.errorf(name, lineNum, "too many nested $include")
+			return
+		}
+		if err := p.include(rest); err != nil {
+			p.errorf(name, lineNum, "%v", err)
+		}
+	default:
+		p.errorf(name, lineNum, "unknown directive: %s", word)
+	}
+}
+
+// eval evaluates the condition of an $if directive.
+func (p *inputrcParser) eval(cond string) bool {
+	cond = strings.TrimSpace(cond)
+	switch {
+	case strings.HasPrefix(cond, "mode="):
+		mode := strings.TrimPrefix(cond, "mode=")
+		return (mode == "emacs" && p.mode == EmacsMode) || (mode == "vi" && p.mode != EmacsMode)
+	case strings.HasPrefix(cond, "term="):
+		term := strings.TrimPrefix(cond, "term=")
+		// As in readline, the condition also matches the portion of
+		// the terminal name before the first '-'.
+		short, _, _ := strings.Cut(p.term, "-")
+		return term == p.term || term == short
+	default:
+		return p.app != "" && cond == p.app
+	}
+}
+
+// set processes a variable setting.
+func (p *inputrcParser) set(name string, lineNum int, def string) {
+	variable, value := splitWord(def)
+	if variable == "" {
+		p.errorf(name, lineNum, "missing variable name")
+		return
+	}
+	value = strings.ToLower(value)
+	switch variable {
+	case "editing-mode":
+		switch value {
+		case "emacs":
+			p.mode = EmacsMode
+			p.viCommand = false
+		case "vi":
+			p.mode = ViInsertMode
+			p.viCommand = false
+		default:
+			p.errorf(name, lineNum, "invalid editing mode: %q", value)
+			return
+		}
+	case "keymap":
+		p.viCommand = value == "vi" || value == "vi-command" || value == "vi-move"
+	}
+	p.rc.Variables[variable] = value
+}
+
+// binding processes a key binding.
+func (p *inputrcParser) binding(name string, lineNum int, line string) {
+	var keys []string
+	var rest string
+	var err error
+	if line[0] == '"' {
+		var seq string
+		seq, rest, err = splitQuoted(line)
+		if err == nil {
+			keys, err = parseKeySeq(seq)
+		}
+	} else {
+		var keyName string
+		keyName, rest, _ = strings.Cut(line, ":")
+		if strings.ContainsFunc(keyName, unicode.IsSpace) {
+			err = fmt.Errorf("invalid key name: %q", keyName)
+		} else {
+			keys, err = parseKeyName(keyName)
+		}
+	}
+	if err != nil {
+		p.errorf(name, lineNum, "%v", err)
+		return
+	}
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, ":") && line[0] == '"' {
+		p.errorf(name, lineNum, "missing ':' after key sequence")
+		return
+	}
+	fn, _ := splitWord(strings.TrimSpace(strings.TrimPrefix(rest, ":")))
+	if fn == "" {
+		p.errorf(name, lineNum, "missing function name")
+		return
+	}
+	if fn[0] == '"' || fn[0] == '\'' {
+		p.errorf(name, lineNum, "macros are not supported")
+		return
+	}
+	binding, ok := ReadlineFunctions[fn]
+	if !ok {
+		if _, found := keyMapBinding(&KeyMap{}, fn); found {
+			binding, ok = fn, true
+		}
+	}
+	if !ok {
+		p.errorf(name, lineNum, "unknown function: %s", fn)
+		return
+	}
+	if p.viCommand {
+		return
+	}
+	p.rc.Bindings = append(p.rc.Bindings, InputrcBinding{
+		Keys:     strings.Join(keys, " "),
+		Function: fn,
+		Binding:  binding,
+	})
+}
+
+// splitWord splits s into its first word and the remainder, with
+// surrounding spaces removed.
+func splitWord(s string) (word, rest string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexFunc(s, unicode.IsSpace); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+// splitQuoted splits s, which must start with a double quote, into the
+// quoted string, escapes included, and the remainder after the closing
+// quote.
+func splitQuoted(s string) (quoted, rest string, err error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return s[1:i], s[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated key sequence: %s", s)
+}
+
+// inputrcKey is one key press in a key sequence.
+type inputrcKey struct {
+	r   rune
+	alt bool
+}
+
+// parseKeySeq parses the contents of a quoted key sequence and returns
+// the corresponding keys.
+func parseKeySeq(seq string) ([]string, error) {
+	src := []rune(seq)
+	var keys []inputrcKey
+	for i := 0; i < len(src); {
+		k, n, err := parseKeySeqChar(src[i:])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		i += n
+	}
+	return keyStrings(keys)
+}
+
+// parseKeySeqChar parses one character, possibly escaped, at the start
+// of src. It returns the number of runes consumed.
+func parseKeySeqChar(src []rune) (k inputrcKey, n int, err error) {
+	if src[0] != '\\' {
+		return inputrcKey{r: src[0]}, 1, nil
+	}
+	if len(src) < 2 {
+		return k, 0, errors.New("incomplete escape sequence")
+	}
+	if len(src) >= 3 && src[2] == '-' && (src[1] == 'C' || src[1] == 'M') {
+		if len(src) < 4 {
+			return k, 0, fmt.Errorf("incomplete escape sequence: %s", string(src))
+		}
+		k, n, err = parseKeySeqChar(src[3:])
+		if err != nil {
+			return k, 0, err
+		}
+		if src[1] == 'C' {
+			k.r = controlChar(k.r)
+		} else {
+			k.alt = true
+		}
+		return k, n + 3, nil
+	}
+	switch c := src[1]; c {
+	case 'e':
+		return inputrcKey{r: 27}, 2, nil
+	case 'a':
+		return inputrcKey{r: 7}, 2, nil
+	case 'b':
+		return inputrcKey{r: 8}, 2, nil
+	case 'd':
+		return inputrcKey{r: 127}, 2, nil
+	case 'f':
+		return inputrcKey{r: 12}, 2, nil
+	case 'n':
+		return inputrcKey{r: 10}, 2, nil
+	case 'r':
+		return inputrcKey{r: 13}, 2, nil
+	case 't':
+		return inputrcKey{r: 9}, 2, nil
+	case 'v':
+		return inputrcKey{r: 11}, 2, nil
+	case 'x':
+		n = 2
+		for n < len(src) && n < 4 && strings.ContainsRune("0123456789abcdefABCDEF", src[n]) {
+			n++
+		}
+		v, err := strconv.ParseUint(string(src[2:n]), 16, 8)
+		if err != nil {
+			return k, 0, fmt.Errorf("invalid hex escape: %s", string(src[:n]))
+		}
+		return inputrcKey{r: rune(v)}, n, nil
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n = 1
+		for n < len(src) && n < 4 && src[n] >= '0' && src[n] <= '7' {
+			n++
+		}
+		v, err := strconv.ParseUint(string(src[1:n]), 8, 8)
+		if err != nil {
+			return k, 0, fmt.Errorf("invalid octal escape: %s", string(src[:n]))
+		}
+		return inputrcKey{r: rune(v)}, n, nil
+	default:
+		// \\, \", \' and any other escaped character stand for
+		// themselves.
+		return inputrcKey{r: c}, 2, nil
+	}
+}
+
+// controlChar returns the control character corresponding to r.
+func controlChar(r rune) rune {
+	if r == '?' {
+		return 127
+	}
+	return unicode.ToUpper(r) & 0x1f
+}
+
+// keyNames is the set of key names recognized in inputrc files.
+var keyNames = map[string]rune{
+	"rubout":  127,
+	"del":     127,
+	"escape":  27,
+	"esc":     27,
+	"lfd":     10,
+	"newline": 10,
+	"return":  13,
+	"ret":     13,
+	"space":   ' ',
+	"spc":     ' ',
+	"tab":     9,
+}
+
+// parseKeyName parses a key name like Control-u or Meta-Rubout and
+// returns the corresponding key.
+func parseKeyName(name string) ([]string, error) {
+	var k inputrcKey
+	ctrl := false
+	s := name
+	for {
+		lower := strings.ToLower(s)
+		if len(s) < 3 {
+			break
+		}
+		if p := "control-"; strings.HasPrefix(lower, p) && len(s) > len(p) {
+			ctrl, s = true, s[len(p):]
+		} else if p := "c-"; strings.HasPrefix(lower, p) {
+			ctrl, s = true, s[len(p):]
+		} else if p := "meta-"; strings.HasPrefix(lower, p) && len(s) > len(p) {
+			k.alt, s = true, s[len(p):]
+		} else if p := "m-"; strings.HasPrefix(lower, p) {
+			k.alt, s = true, s[len(p):]
+		} else {
+			break
+		}
+	}
+	if r, ok := keyNames[strings.ToLower(s)]; ok {
+		k.r = r
+	} else if rs := []rune(s); len(rs) == 1 {
+		k.r = rs[0]
+	} else {
+		return nil, fmt.Errorf("invalid key name: %q", name)
+	}
+	if ctrl {
+		k.r = controlChar(k.r)
+	}
+	return keyStrings([]inputrcKey{k})
+}
+
+// csiKeys maps the escape sequences of special keys, without the
+// leading escape character, to their key name.
+var csiKeys = map[string]string{
+	"[A": "up", "[B": "down", "[C": "right", "[D": "left",
+	"[H": "home", "[F": "end",
+	"OA": "up", "OB": "down", "OC": "right", "OD": "left",
+	"OH": "home", "OF": "end",
+	"[1~": "home", "[2~": "insert", "[3~": "delete", "[4~": "end",
+	"[5~": "pgup", "[6~": "pgdown",
+}
+
+// keyStrings converts a key sequence to the notation used by
+// key.Binding. An escape character followed by another key is
+// converted to the Alt modifier on that key, as terminals do.
+func keyStrings(keys []inputrcKey) ([]string, error) {
+	var res []string
+	for i := 0; i < len(keys); i++ {
+		k := keys[i]
+		if k.r == 27 && !k.alt && i+1 < len(keys) {
+			if name, n := matchCSIKey(keys[i+1:]); n > 0 {
+				res = append(res, name)
+				i += n
+				continue
+			}
+			i++
+			k = keys[i]
+			k.alt = true
+		}
+		var tk tea.Key
+		switch {
+		case k.r == ' ':
+			if len(keys) > 1 {
+				return nil, errors.New("space is not supported in multi-key sequences")
+			}
+			tk = tea.Key{Type: tea.KeySpace, Runes: []rune{' '}}
+		case k.r < 32 || k.r == 127:
+			tk = tea.Key{Type: tea.KeyType(k.r)}
+		default:
+			tk = tea.Key{Type: tea.KeyRunes, Runes: []rune{k.r}}
+		}
+		tk.Alt = k.alt
+		res = append(res, tk.String())
+	}
+	if len(res) == 0 {
+		return nil, errors.New("empty key sequence")
+	}
+	return res, nil
+}
+
+// matchCSIKey checks whether the keys start with the escape sequence
+// of a special key. It returns the key name and the number of keys
+// matched, or zero if there is no match.
+func matchCSIKey(keys []inputrcKey) (string, int) {
+	var buf strings.Builder
+	for i, k := range keys {
+		if k.alt || i >= 3 {
+			break
+		}
+		buf.WriteRune(k.r)
+		if name, ok := csiKeys[buf.String()]; ok {
+			return name, i + 1
+		}
+	}
+	return "", 0
+}
+
+// keyMapBinding returns the key binding with the specified name in the
+// key map, including the embedded textarea key map.
+func keyMapBinding(km *KeyMap, name string) (*key.Binding, bool) {
+	v := reflect.ValueOf(km).Elem()
+	if f := v.FieldByName(name); f.IsValid() {
+		if b, ok := f.Addr().Interface().(*key.Binding); ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// Bind adds the specified keys to the key binding with the specified
+// name, e.g. "LineStart". The keys are removed from the other bindings
+// they were assigned to, unless they were already assigned to this
+// binding. The key help is updated accordingly: the new keys are added
+// to the help of the binding, and the help of the bindings that lose
+// keys is recomputed from their remaining keys.
+func (k *KeyMap) Bind(name string, keys ...string) error {
+	target, ok := keyMapBinding(k, name)
+	if !ok {
+		return fmt.Errorf("unknown key binding: %s", name)
+	}
+	for _, newKey := range keys {
+		if hasKey(*target, newKey) {
+			continue
+		}
+		forEachBinding(k, func(b *key.Binding) {
+			if b == target || !hasKey(*b, newKey) {
+				return
+			}
+			var remaining []string
+			for _, bk := range b.Keys() {
+				if bk != newKey {
+					remaining = append(remaining, bk)
+				}
+			}
+			b.SetKeys(remaining...)
+			if h := b.Help(); h.Key != "" {
+				b.SetHelp(keyHelp(remaining), h.Desc)
+			}
+		})
+		target.SetKeys(append(append([]string(nil), target.Keys()...), newKey)...)
+		if h := target.Help(); h.Desc != "" {
+			target.SetHelp(strings.TrimPrefix(h.Key+"/"+keyHelp([]string{newKey}), "/"), h.Desc)
+		}
+	}
+	return nil
+}
+
+// hasKey returns true if the binding includes the specified key.
+func hasKey(b key.Binding, k string) bool {
+	for _, bk := range b.Keys() {
+		if bk == k {
+			return true
+		}
+	}
+	return false
+}
+
+// keyHelp returns the help string for the specified keys, in the
+// abbreviated notation used by the default key map.
+func keyHelp(keys []string) string {
+	r := strings.NewReplacer("ctrl+", "C-", "alt+", "M-", "shift+", "S-",
+		"right", "→", "left", "←", "up", "↑", "down", "↓", "backspace", "bksp")
+	help := make([]string, len(keys))
+	for i, k := range keys {
+		help[i] = r.Replace(k)
+	}
+	return strings.Join(help, "/")
+}
+
+// ApplyInputrc applies the key bindings and the editing mode of an
+// inputrc configuration to the editor. It does nothing if rc is nil.
+// Bindings that are disabled, e.g. ExternalEdit when the external
+// editor is not enabled, remain disabled.
+func (m *Model) ApplyInputrc(rc *Inputrc) error {
+	if rc == nil {
+		return nil
+	}
+	var errs []error
+	for _, b := range rc.Bindings {
+		if err := m.KeyMap.Bind(b.Binding, b.Keys); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	m.text.KeyMap = m.KeyMap.KeyMap
+	switch rc.Variables["editing-mode"] {
+	case "emacs":
+		m.SetEditMode(EmacsMode)
+	case "vi":
+		m.SetEditMode(ViInsertMode)
+	}
+	return errors.Join(errs...)
+}
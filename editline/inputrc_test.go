@@ -0,0 +1,114 @@
+package editline_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/knz/bubbline/editline"
+)
+
+func TestParseInputrc(t *testing.T) {
+	td := []struct {
+		input string
+		exp   string
+	}{
+		{``, ``},
+		{`# comment only`, ``},
+		// Key sequences.
+		{`"\C-x\C-e": edit-and-execute`, `ctrl+x ctrl+e -> ExternalEdit`},
+		{`"\M-f": forward-word`, `alt+f -> WordForward`},
+		{`"\ef": forward-word`, `alt+f -> WordForward`},
+		{`"\M-\C-h": backward-kill-word`, `alt+ctrl+h -> DeleteWordBackward`},
+		{`"\C-?": backward-delete-char`, `backspace -> DeleteCharacterBackward`},
+		{`"\t": complete`, `tab -> AutoComplete`},
+		{`"\e[A": previous-history`, `up -> HistoryPrevious`},
+		{`"\e[B": next-history`, `down -> HistoryNext`},
+		{`"\e[3~": delete-char`, `delete -> DeleteCharacterForward`},
+		{`"\C-xa": beginning-of-buffer`, `ctrl+x a -> InputBegin`},
+		{`"\x01": beginning-of-line`, `ctrl+a -> LineStart`},
+		{`"\005": end-of-line`, `ctrl+e -> LineEnd`},
+		// Key names.
+		{`Control-u: unix-line-discard`, `ctrl+u -> DeleteBeforeCursor`},
+		{`C-t: transpose-chars`, `ctrl+t -> TransposeCharacterBackward`},
+		{`Meta-Rubout: backward-kill-word`, `alt+backspace -> DeleteWordBackward`},
+		{`M-u: upcase-word`, `alt+u -> UppercaseWordForward`},
+		{`Tab: complete`, `tab -> AutoComplete`},
+		// Binding names can be used as function names.
+		{`"\C-xe": InputEnd`, `ctrl+x e -> InputEnd`},
+		// Variables.
+		{`set editing-mode vi`, `editing-mode = vi`},
+		{`set bell-style none`, `bell-style = none`},
+		// Conditionals.
+		{"$if mode=emacs\nC-t: transpose-chars\n$else\nC-t: backward-word\n$endif",
+			`ctrl+t -> TransposeCharacterBackward`},
+		{"set editing-mode vi\n$if mode=emacs\nC-t: transpose-chars\n$else\nC-t: backward-word\n$endif",
+			"ctrl+t -> WordBackward\nediting-mode = vi"},
+		{"$if myapp\nC-t: transpose-chars\n$endif", `ctrl+t -> TransposeCharacterBackward`},
+		{"$if otherapp\nC-t: transpose-chars\n$endif", ``},
+		{"$if term=nonexistent\nC-t: transpose-chars\n$endif", ``},
+		// Bindings in the vi command keymap are ignored.
+		{"set keymap vi-command\nC-t: transpose-chars\nset keymap vi-insert\nC-u: unix-line-discard",
+			"ctrl+u -> DeleteBeforeCursor\nkeymap = vi-insert"},
+		// Errors.
+		{`"\C-xa": "some macro"`, `error: inputrc:1: macros are not supported`},
+		{`C-t: no-such-function`, `error: inputrc:1: unknown function: no-such-function`},
+		{`"\C-t transpose-chars`, `error: inputrc:1: unterminated key sequence: "\C-t transpose-chars`},
+		{`"\C-t" transpose-chars`, `error: inputrc:1: missing ':' after key sequence`},
+		{`$endif`, `error: inputrc:1: $endif without $if`},
+		{"$if mode=emacs\nC-t: transpose-chars", "ctrl+t -> TransposeCharacterBackward\nerror: inputrc:2: missing $endif"},
+		{"C-t: foo\nC-u: unix-line-discard", "ctrl+u -> DeleteBeforeCursor\nerror: inputrc:1: unknown function: foo"},
+	}
+
+	for i, tc := range td {
+		rc, err := editline.ParseInputrc(strings.NewReader(tc.input), "myapp")
+		var res []string
+		for _, b := range rc.Bindings {
+			res = append(res, fmt.Sprintf("%s -> %s", b.Keys, b.Binding))
+		}
+		var vars []string
+		for k, v := range rc.Variables {
+			vars = append(vars, fmt.Sprintf("%s = %s", k, v))
+		}
+		sort.Strings(vars)
+		res = append(res, vars...)
+		if err != nil {
+			res = append(res, fmt.Sprintf("error: %v", err))
+		}
+		if actual := strings.Join(res, "\n"); actual != tc.exp {
+			t.Errorf("%d: %q: expected:\n%s\ngot:\n%s", i, tc.input, tc.exp, actual)
+		}
+	}
+}
+
+func TestKeyMapBind(t *testing.T) {
+	km := editline.DefaultKeyMap
+	if err := km.Bind("WordBackward", "ctrl+t"); err != nil {
+		t.Fatal(err)
+	}
+	if actual := km.WordBackward.Keys(); strings.Join(actual, " ") != "alt+left ctrl+left alt+b ctrl+t" {
+		t.Errorf("unexpected keys: %q", actual)
+	}
+	if actual := km.WordBackward.Help().Key; actual != "M-b/C-←/C-t" {
+		t.Errorf("unexpected help: %q", actual)
+	}
+	if actual := km.TransposeCharacterBackward.Keys(); len(actual) != 0 {
+		t.Errorf("expected key to be unbound, got: %q", actual)
+	}
+	// Binding a key already assigned to the binding leaves the other
+	// bindings alone.
+	if err := km.Bind("DeleteCharacterForward", "ctrl+d"); err != nil {
+		t.Fatal(err)
+	}
+	if actual := km.EndOfInput.Keys(); len(actual) != 1 || actual[0] != "ctrl+d" {
+		t.Errorf("unexpected keys: %q", actual)
+	}
+	if err := km.Bind("NoSuchBinding", "ctrl+t"); err == nil {
+		t.Errorf("expected error")
+	}
+	// The default key map is not modified.
+	if actual := editline.DefaultKeyMap.TransposeCharacterBackward.Keys(); len(actual) != 1 {
+		t.Errorf("default key map modified: %q", actual)
+	}
+}
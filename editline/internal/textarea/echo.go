@@ -0,0 +1,79 @@
+package textarea
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// EchoMode sets how the text area displays its contents.
+type EchoMode int
+
+const (
+	// EchoNormal displays the text as is.
+	EchoNormal EchoMode = iota
+
+	// EchoPassword displays EchoCharacter instead of each character.
+	EchoPassword
+
+	// EchoNone displays nothing.
+	EchoNone
+)
+
+// maskValue replaces the value by what should be displayed according
+// to the echo mode. It must only be called on a copy of the model,
+// for display.
+func (m *Model) maskValue() {
+	if m.EchoMode == EchoNormal {
+		return
+	}
+	masked := make([][]rune, len(m.value))
+	for i, l := range m.value {
+		if m.EchoMode == EchoPassword {
+			masked[i] = make([]rune, len(l))
+			for j := range l {
+				masked[i][j] = m.EchoCharacter
+			}
+		} else {
+			masked[i] = []rune{}
+		}
+	}
+	m.value = masked
+	if m.EchoMode == EchoNone {
+		// Nothing to show: neither the cursor position nor the region.
+		m.col = 0
+		m.mark.active = false
+	}
+	m.Highlighter = nil
+	m.suggestion = ""
+}
+
+// Wipe overwrites the input, the undo history and the last vi change
+// in memory, then resets the text area. This is meant to clear
+// secrets, such as passwords, from memory.
+func (m *Model) Wipe() {
+	wipe := func(value [][]rune) {
+		for _, l := range value {
+			// Also wipe the characters beyond the end of the line, which
+			// may have been deleted.
+			l = l[:cap(l)]
+			for j := range l {
+				l[j] = 0
+			}
+		}
+	}
+	wipe(m.value)
+	for _, s := range m.undo.undo {
+		wipe(s.value)
+	}
+	for _, s := range m.undo.redo {
+		wipe(s.value)
+	}
+	// The keys of the vi command in progress and of the last change
+	// may also contain parts of the input.
+	for _, keys := range [][]tea.KeyMsg{m.vi.cmdKeys, m.vi.lastChange} {
+		for _, k := range keys {
+			for j := range k.Runes {
+				k.Runes[j] = 0
+			}
+		}
+	}
+	m.vi = viState{enabled: m.vi.enabled}
+	m.Reset()
+}
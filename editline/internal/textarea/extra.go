@@ -5,6 +5,14 @@ import (
 	"strings"
 )
 
+// KeySeqMsg represents a sequence of multiple key presses, for
+// example "ctrl+x ctrl+u". It can be matched against key bindings like
+// a tea.KeyMsg, and is processed by Update in the same way.
+type KeySeqMsg string
+
+// String implements the fmt.Stringer interface.
+func (k KeySeqMsg) String() string { return string(k) }
+
 // EmptyValue returns true iff the value is empty.
 func (m *Model) EmptyValue() bool {
 	return len(m.value) == 0 || (len(m.value) == 1 && len(m.value[0]) == 0)
@@ -96,6 +104,8 @@ func (m Model) Debug() string {
 	fmt.Fprintf(&buf, "width: %d, height: %d\n", m.width, m.height)
 	fmt.Fprintf(&buf, "col: %d, row: %d\n", m.col, m.row)
 	fmt.Fprintf(&buf, "lastCharOffset: %d\n", m.lastCharOffset)
+	fmt.Fprintf(&buf, "mark: col %d, row %d\n", m.mark.col, m.mark.row)
+	fmt.Fprintf(&buf, "markActive: %v\n", m.mark.active)
 	for l, line := range m.value {
 		fmt.Fprintf(&buf, "line %d: %v (%q)\n", l, line, string(line))
 	}
@@ -0,0 +1,112 @@
+package textarea
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StyleSpan is a range of characters in one line of the input that
+// is rendered with a specific style.
+type StyleSpan struct {
+	// Start and End are the positions of the first character in the
+	// span and of the first character after the span, in runes from
+	// the beginning of the line.
+	Start, End int
+	// Style is the style to apply. It is combined with the style of
+	// the line, so that for example the background of the cursor
+	// line is preserved unless the span sets one.
+	Style lipgloss.Style
+}
+
+// HighlightFn is the type of a function that styles the text of the
+// input, for example for syntax highlighting. It is called with the
+// entire input every time the input is rendered, and returns the
+// spans to apply to each line: the i-th entry of the result applies
+// to the i-th line. The result can be shorter than the input if the
+// last lines have no spans.
+//
+// When spans overlap, the later ones take precedence.
+type HighlightFn func(value [][]rune) [][]StyleSpan
+
+// textStyle returns the style of the character at the specified
+// position, given the style of the line.
+func (m Model) textStyle(style lipgloss.Style, row, col int) lipgloss.Style {
+	if row < len(m.highlights) && col < len(m.value[row]) {
+		spans := m.highlights[row]
+		for i := len(spans) - 1; i >= 0; i-- {
+			if col >= spans[i].Start && col < spans[i].End {
+				style = spans[i].Style.Inherit(style)
+				break
+			}
+		}
+	}
+	return style
+}
+
+// renderText renders the runes of the specified row, starting at the
+// specified column. The highlights are applied, and the part inside
+// the active region, if any, is rendered with the selection style.
+func (m Model) renderText(style lipgloss.Style, row, col int, runes []rune) string {
+	hasSpans := row < len(m.highlights) && len(m.highlights[row]) > 0
+	if len(runes) == 0 || (!hasSpans && !m.mark.active) {
+		return style.Render(string(runes))
+	}
+
+	// spanIdx[i] is the index of the span applying to runes[i], or -1.
+	spanIdx := make([]int, len(runes))
+	for i := range spanIdx {
+		spanIdx[i] = -1
+	}
+	if hasSpans {
+		// The spans do not apply past the end of the line, e.g. to the
+		// trailing space where the cursor is displayed.
+		lineEnd := clamp(len(m.value[row])-col, 0, len(runes))
+		for si, span := range m.highlights[row] {
+			from := clamp(span.Start-col, 0, lineEnd)
+			to := clamp(span.End-col, from, lineEnd)
+			for i := from; i < to; i++ {
+				spanIdx[i] = si
+			}
+		}
+	}
+
+	// The region covers runes[selFrom:selTo].
+	selFrom, selTo := 0, 0
+	if m.mark.active {
+		startRow, startCol, endRow, endCol := m.region()
+		if row >= startRow && row <= endRow {
+			// The newline at the end of the row, rendered as a trailing
+			// space, is also part of the region if the region continues
+			// below.
+			from, to := 0, len(m.value[row])+1
+			if row == startRow {
+				from = startCol
+			}
+			if row == endRow {
+				to = endCol
+			}
+			selFrom = clamp(from-col, 0, len(runes))
+			selTo = clamp(to-col, selFrom, len(runes))
+		}
+	}
+
+	var buf strings.Builder
+	for start := 0; start < len(runes); {
+		selected := start >= selFrom && start < selTo
+		end := start + 1
+		for end < len(runes) && spanIdx[end] == spanIdx[start] && (end >= selFrom && end < selTo) == selected {
+			end++
+		}
+		s := style
+		if si := spanIdx[start]; si >= 0 {
+			s = m.highlights[row][si].Style.Inherit(style)
+		}
+		if selected {
+			s = m.style.Selection.Inherit(s)
+		}
+		buf.WriteString(s.Render(string(runes[start:end])))
+		start = end
+	}
+	return buf.String()
+}
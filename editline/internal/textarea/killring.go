@@ -0,0 +1,171 @@
+package textarea
+
+import "strings"
+
+// defaultKillRingSize is the default maximum number of entries in the
+// kill ring.
+const defaultKillRingSize = 60
+
+// command identifies the kind of an editing command. It is used to
+// determine whether consecutive kills should be merged together,
+// whether yank-pop is possible and how to group changes for undo.
+type command int
+
+const (
+	cmdOther command = iota
+	cmdKill
+	cmdYank
+	cmdInsert
+	cmdDeleteChar
+)
+
+// killRing stores the text removed by the kill commands, so that it
+// can be re-inserted with yank.
+type killRing struct {
+	// entries is the list of killed texts, most recent last.
+	entries []string
+
+	// yankIdx is the index in entries of the text inserted by the
+	// last yank or yank-pop.
+	yankIdx int
+	// yankRow/yankCol is the position where the last yanked text was
+	// inserted.
+	yankRow, yankCol int
+}
+
+// KillRing retrieves the entries in the kill ring, most recent last.
+func (m *Model) KillRing() []string {
+	return m.killRing.entries
+}
+
+// SetKillRing sets the entries in the kill ring all at once, most
+// recent last.
+func (m *Model) SetKillRing(entries []string) {
+	if m.MaxKillRingSize > 0 && len(entries) > m.MaxKillRingSize {
+		entries = entries[len(entries)-m.MaxKillRingSize:]
+	}
+	m.killRing.entries = append([]string(nil), entries...)
+	m.killRing.yankIdx = 0
+}
+
+// kill adds the specified text to the kill ring. If the previous
+// command was also a kill, the text is merged into the last entry:
+// prepended if backward is set, appended otherwise.
+func (m *Model) kill(s string, backward bool) {
+	if s == "" || m.EchoMode != EchoNormal {
+		// Note: the text of secrets is not preserved.
+		return
+	}
+	kr := &m.killRing
+	if m.lastCmd == cmdKill && len(kr.entries) > 0 {
+		last := &kr.entries[len(kr.entries)-1]
+		if backward {
+			*last = s + *last
+		} else {
+			*last += s
+		}
+	} else {
+		m.pushKill(s)
+	}
+	m.thisCmd = cmdKill
+}
+
+// pushKill adds a new entry to the kill ring, evicting the oldest
+// entry if the ring is full.
+func (m *Model) pushKill(s string) {
+	if m.EchoMode != EchoNormal {
+		return
+	}
+	kr := &m.killRing
+	kr.entries = append(kr.entries, s)
+	if m.MaxKillRingSize > 0 && len(kr.entries) > m.MaxKillRingSize {
+		copy(kr.entries, kr.entries[1:])
+		kr.entries = kr.entries[:m.MaxKillRingSize]
+	}
+}
+
+// yank inserts the most recently killed text at the cursor.
+func (m *Model) yank() {
+	kr := &m.killRing
+	if len(kr.entries) == 0 {
+		return
+	}
+	kr.yankIdx = len(kr.entries) - 1
+	m.insertYank()
+}
+
+// yankPop replaces the text inserted by the last yank with the
+// previous entry in the kill ring. It is a no-op if the previous
+// command was not a yank.
+func (m *Model) yankPop() {
+	kr := &m.killRing
+	if m.lastCmd != cmdYank || len(kr.entries) == 0 {
+		return
+	}
+	// Check that the yanked text is still there, right before the
+	// cursor. It may have been modified since by the containing
+	// editor.
+	if kr.yankRow > m.row || (kr.yankRow == m.row && kr.yankCol > m.col) ||
+		m.textRange(kr.yankRow, kr.yankCol, m.row, m.col) != kr.entries[kr.yankIdx] {
+		return
+	}
+	m.deleteRange(kr.yankRow, kr.yankCol, m.row, m.col)
+	kr.yankIdx = (kr.yankIdx + len(kr.entries) - 1) % len(kr.entries)
+	m.insertYank()
+}
+
+// insertYank inserts the current yank entry at the cursor.
+func (m *Model) insertYank() {
+	kr := &m.killRing
+	kr.yankRow, kr.yankCol = m.row, m.col
+	m.insertRunesFromUserInput([]rune(kr.entries[kr.yankIdx]))
+	m.thisCmd = cmdYank
+}
+
+// killLineBelow merges the specified line with the line below,
+// recording the removed newline in the kill ring.
+func (m *Model) killLineBelow(row int) {
+	if row < len(m.value)-1 {
+		m.kill("\n", false /* backward */)
+	}
+	m.mergeLineBelow(row)
+}
+
+// killLineAbove merges the specified line with the line above,
+// recording the removed newline in the kill ring.
+func (m *Model) killLineAbove(row int) {
+	if row > 0 {
+		m.kill("\n", true /* backward */)
+	}
+	m.mergeLineAbove(row)
+}
+
+// textRange returns the text between the two specified positions.
+// The start position must not be after the end position.
+func (m *Model) textRange(startRow, startCol, endRow, endCol int) string {
+	if startRow == endRow {
+		return string(m.value[startRow][startCol:endCol])
+	}
+	var buf strings.Builder
+	buf.WriteString(string(m.value[startRow][startCol:]))
+	for row := startRow + 1; row < endRow; row++ {
+		buf.WriteByte('\n')
+		buf.WriteString(string(m.value[row]))
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(string(m.value[endRow][:endCol]))
+	return buf.String()
+}
+
+// deleteRange removes the text between the two specified positions
+// and places the cursor at the start position. The start position
+// must not be after the end position.
+func (m *Model) deleteRange(startRow, startCol, endRow, endCol int) {
+	// Beware to clamp the capacity of the head, so that the append
+	// does not overwrite the tail when both are on the same row.
+	head := m.value[startRow][:startCol:startCol]
+	m.value[startRow] = append(head, m.value[endRow][endCol:]...)
+	m.value = append(m.value[:startRow+1], m.value[endRow+1:]...)
+	m.row = startRow
+	m.SetCursor(startCol)
+}
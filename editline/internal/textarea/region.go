@@ -0,0 +1,212 @@
+package textarea
+
+import (
+	"strings"
+	"unicode"
+)
+
+// defaultIndent is the default text inserted by the indent command.
+const defaultIndent = "  "
+
+// defaultCommentPrefix is the default text inserted at the start of
+// lines by the comment toggle command.
+const defaultCommentPrefix = "# "
+
+// markState is the position of the mark, which delimits the region
+// together with the cursor.
+type markState struct {
+	row, col int
+	// active is true when the region is active, i.e. when the region
+	// commands apply to it and it is highlighted.
+	active bool
+}
+
+// RegionActive returns true iff a region is currently selected.
+func (m *Model) RegionActive() bool {
+	return m.mark.active
+}
+
+// SetMark sets the mark at the cursor position and activates the
+// region.
+func (m *Model) SetMark() {
+	m.mark = markState{row: m.row, col: m.col, active: true}
+}
+
+// DeactivateMark deactivates the region, if any.
+func (m *Model) DeactivateMark() {
+	m.mark.active = false
+}
+
+// toggleMark sets the mark at the cursor, or deactivates the region if
+// the mark is already active at the cursor.
+func (m *Model) toggleMark() {
+	if m.mark.active && m.mark.row == m.row && m.mark.col == m.col {
+		m.DeactivateMark()
+		return
+	}
+	m.SetMark()
+}
+
+// region returns the start and end positions of the region, in
+// order. The positions are clamped to the current value.
+func (m *Model) region() (startRow, startCol, endRow, endCol int) {
+	mrow := clamp(m.mark.row, 0, len(m.value)-1)
+	mcol := clamp(m.mark.col, 0, len(m.value[mrow]))
+	prow, pcol := m.row, clamp(m.col, 0, len(m.value[m.row]))
+	if mrow < prow || (mrow == prow && mcol < pcol) {
+		return mrow, mcol, prow, pcol
+	}
+	return prow, pcol, mrow, mcol
+}
+
+// RegionText returns the text in the region, or the empty string if
+// there is no active region.
+func (m *Model) RegionText() string {
+	if !m.mark.active {
+		return ""
+	}
+	return m.textRange(m.region())
+}
+
+// killRegion removes the text in the region and adds it to the kill
+// ring.
+func (m *Model) killRegion() {
+	startRow, startCol, endRow, endCol := m.region()
+	backward := m.row == startRow && m.col == startCol
+	m.kill(m.textRange(startRow, startCol, endRow, endCol), backward)
+	m.deleteRange(startRow, startCol, endRow, endCol)
+	m.DeactivateMark()
+}
+
+// copyRegion adds the text in the region to the kill ring.
+func (m *Model) copyRegion() {
+	m.kill(m.textRange(m.region()), false /* backward */)
+	// Copying does not merge with a subsequent kill.
+	m.thisCmd = cmdOther
+	m.DeactivateMark()
+}
+
+// mapRegion applies fn to every character in the region.
+func (m *Model) mapRegion(fn func(r rune) rune) {
+	startRow, startCol, endRow, endCol := m.region()
+	for row := startRow; row <= endRow; row++ {
+		line := m.value[row]
+		from, to := 0, len(line)
+		if row == startRow {
+			from = startCol
+		}
+		if row == endRow {
+			to = endCol
+		}
+		for i := from; i < to; i++ {
+			line[i] = fn(line[i])
+		}
+	}
+	m.DeactivateMark()
+}
+
+// capitalizeRegion capitalizes every word in the region.
+func (m *Model) capitalizeRegion() {
+	prevLetter := false
+	m.mapRegion(func(r rune) rune {
+		isLetter := unicode.IsLetter(r) || unicode.IsDigit(r)
+		atWordStart := isLetter && !prevLetter
+		prevLetter = isLetter
+		if atWordStart {
+			return unicode.ToUpper(r)
+		}
+		return unicode.ToLower(r)
+	})
+}
+
+// regionLines returns the range of lines covered by the region, or
+// the current line if there is no active region. A region that ends
+// at the start of a line does not cover that line.
+func (m *Model) regionLines() (startRow, endRow int) {
+	if !m.mark.active {
+		return m.row, m.row
+	}
+	startRow, _, endRow, endCol := m.region()
+	if endRow > startRow && endCol == 0 {
+		endRow--
+	}
+	return startRow, endRow
+}
+
+// insertAtLineStart inserts the specified text at the beginning of
+// the specified line, keeping the cursor at the same place in the
+// text.
+func (m *Model) insertAtLineStart(row int, s string) {
+	prefix := []rune(s)
+	m.value[row] = append(prefix, m.value[row]...)
+	if row == m.row {
+		m.SetCursor(m.col + len(prefix))
+	}
+}
+
+// removeAtLineStart removes n characters at the beginning of the
+// specified line, keeping the cursor at the same place in the text.
+func (m *Model) removeAtLineStart(row int, n int) {
+	m.value[row] = m.value[row][n:]
+	if row == m.row {
+		m.SetCursor(max(0, m.col-n))
+	}
+}
+
+// indentRegion indents the lines in the region.
+func (m *Model) indentRegion() {
+	startRow, endRow := m.regionLines()
+	for row := startRow; row <= endRow; row++ {
+		if len(m.value[row]) > 0 {
+			m.insertAtLineStart(row, m.Indent)
+		}
+	}
+	m.DeactivateMark()
+}
+
+// dedentRegion removes one level of indentation from the lines in the
+// region.
+func (m *Model) dedentRegion() {
+	startRow, endRow := m.regionLines()
+	width := len([]rune(m.Indent))
+	for row := startRow; row <= endRow; row++ {
+		n := 0
+		for n < width && n < len(m.value[row]) && unicode.IsSpace(m.value[row][n]) {
+			n++
+		}
+		m.removeAtLineStart(row, n)
+	}
+	m.DeactivateMark()
+}
+
+// toggleComment comments out the lines in the region, or uncomments
+// them if they are all commented out already.
+func (m *Model) toggleComment() {
+	prefix := m.CommentPrefix
+	if prefix == "" {
+		return
+	}
+	// The prefix without trailing space is also recognized, since
+	// editors tend to strip trailing spaces on empty comment lines.
+	shortPrefix := strings.TrimRightFunc(prefix, unicode.IsSpace)
+	startRow, endRow := m.regionLines()
+	uncomment := true
+	for row := startRow; row <= endRow; row++ {
+		if !strings.HasPrefix(string(m.value[row]), shortPrefix) {
+			uncomment = false
+			break
+		}
+	}
+	for row := startRow; row <= endRow; row++ {
+		if !uncomment {
+			m.insertAtLineStart(row, prefix)
+			continue
+		}
+		n := len([]rune(shortPrefix))
+		if strings.HasPrefix(string(m.value[row]), prefix) {
+			n = len([]rune(prefix))
+		}
+		m.removeAtLineStart(row, n)
+	}
+	m.DeactivateMark()
+}
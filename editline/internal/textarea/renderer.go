@@ -0,0 +1,19 @@
+package textarea
+
+import "github.com/charmbracelet/lipgloss"
+
+// Renderer returns a copy of the style where all the component
+// styles are bound to the given lipgloss renderer.
+func (s Style) Renderer(r *lipgloss.Renderer) Style {
+	s.Base = s.Base.Renderer(r)
+	s.CursorLine = s.CursorLine.Renderer(r)
+	s.CursorLineNumber = s.CursorLineNumber.Renderer(r)
+	s.EndOfBuffer = s.EndOfBuffer.Renderer(r)
+	s.LineNumber = s.LineNumber.Renderer(r)
+	s.Placeholder = s.Placeholder.Renderer(r)
+	s.Prompt = s.Prompt.Renderer(r)
+	s.Text = s.Text.Renderer(r)
+	s.Selection = s.Selection.Renderer(r)
+	s.Suggestion = s.Suggestion.Renderer(r)
+	return s
+}
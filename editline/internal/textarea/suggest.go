@@ -0,0 +1,40 @@
+package textarea
+
+import (
+	"strings"
+
+	rw "github.com/mattn/go-runewidth"
+)
+
+// SetSuggestion sets the text displayed after the cursor when the
+// cursor is at the end of the input. The suggestion is only displayed:
+// it is not part of the value until it is inserted with InsertString.
+// Only the first line of the suggestion is displayed.
+func (m *Model) SetSuggestion(s string) {
+	m.suggestion = s
+}
+
+// Suggestion returns the current suggestion.
+func (m *Model) Suggestion() string {
+	return m.suggestion
+}
+
+// AtEndOfInput returns true if the cursor is at the end of the input.
+func (m *Model) AtEndOfInput() bool {
+	return m.row == len(m.value)-1 && m.col >= len(m.value[m.row])
+}
+
+// suggestionView returns the part of the suggestion to display after
+// the cursor on the specified row, truncated to the specified width.
+// It returns nil if there is no suggestion to display.
+func (m Model) suggestionView(row, width int) []rune {
+	if m.suggestion == "" || !m.focus || row != m.row || !m.AtEndOfInput() {
+		return nil
+	}
+	s, _, _ := strings.Cut(m.suggestion, "\n")
+	s = rw.Truncate(s, width, "")
+	if s == "" {
+		return nil
+	}
+	return []rune(s)
+}
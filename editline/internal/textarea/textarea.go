@@ -61,6 +61,16 @@ type KeyMap struct {
 	UppercaseWordForward       key.Binding
 	LowercaseWordForward       key.Binding
 	CapitalizeWordForward      key.Binding
+
+	Yank    key.Binding
+	YankPop key.Binding
+
+	SetMark       key.Binding
+	KillRegion    key.Binding
+	CopyRegion    key.Binding
+	IndentRegion  key.Binding
+	DedentRegion  key.Binding
+	ToggleComment key.Binding
 }
 
 // DefaultKeyMap is the default set of key bindings for navigating and acting
@@ -92,6 +102,16 @@ var DefaultKeyMap = KeyMap{
 	UppercaseWordForward:       key.NewBinding(key.WithKeys("alt+u"), key.WithHelp("M-u", "uppercase word")),
 
 	ToggleOverwriteMode: key.NewBinding(key.WithKeys("insert", "alt+o"), key.WithHelp("M-o/ins", "toggle overwrite")),
+
+	Yank:    key.NewBinding(key.WithKeys("ctrl+y"), key.WithHelp("C-y", "yank")),
+	YankPop: key.NewBinding(key.WithKeys("alt+y"), key.WithHelp("M-y", "yank prev kill")),
+
+	SetMark:       key.NewBinding(key.WithKeys("ctrl+@"), key.WithHelp("C-spc", "set mark")),
+	KillRegion:    key.NewBinding(key.WithKeys("ctrl+w"), key.WithHelp("C-w", "cut region")),
+	CopyRegion:    key.NewBinding(key.WithKeys("alt+w"), key.WithHelp("M-w", "copy region")),
+	IndentRegion:  key.NewBinding(key.WithKeys("ctrl+x tab"), key.WithHelp("C-x tab", "indent")),
+	DedentRegion:  key.NewBinding(key.WithKeys("ctrl+x shift+tab"), key.WithHelp("C-x S-tab", "dedent")),
+	ToggleComment: key.NewBinding(key.WithKeys("alt+;"), key.WithHelp("M-;", "toggle comment")),
 }
 
 // LineInfo is a helper for keeping track of line information regarding
@@ -135,6 +155,8 @@ type Style struct {
 	Placeholder      lipgloss.Style
 	Prompt           lipgloss.Style
 	Text             lipgloss.Style
+	Selection        lipgloss.Style
+	Suggestion       lipgloss.Style
 }
 
 // Model is the Bubble Tea model for this text area element.
@@ -190,6 +212,40 @@ type Model struct {
 	// there's no limit.
 	MaxWidth int
 
+	// MaxKillRingSize is the maximum number of entries in the kill
+	// ring. If 0 or less, there's no limit.
+	MaxKillRingSize int
+
+	// UndoMemoryLimit is the maximum amount of memory, in bytes, used
+	// to store the undo history. If 0 or less, there's no limit.
+	UndoMemoryLimit int
+
+	// Indent is the text inserted at the beginning of lines by the
+	// indent command.
+	Indent string
+
+	// CommentPrefix is the text inserted at the beginning of lines by
+	// the comment toggle command. If empty, the command is disabled.
+	CommentPrefix string
+
+	// Highlighter, if set, is called to style the text of the input.
+	// See the documentation of HighlightFn for details.
+	Highlighter HighlightFn
+
+	// EchoMode determines how the text is displayed. See EchoMode.
+	EchoMode EchoMode
+
+	// EchoCharacter is displayed instead of each character in the
+	// EchoPassword mode.
+	EchoCharacter rune
+
+	// highlights is the result of Highlighter during View().
+	highlights [][]StyleSpan
+
+	// suggestion is the text displayed after the cursor at the end of
+	// the input. See SetSuggestion().
+	suggestion string
+
 	// If promptFunc is set, it replaces Prompt as a generator for
 	// prompt strings at the beginning of each line.
 	promptFunc func(line int) string
@@ -235,6 +291,22 @@ type Model struct {
 
 	// rune sanitizer for input.
 	rsan runeutil.Sanitizer
+
+	// killRing is the text removed by the kill commands.
+	killRing killRing
+
+	// undo is the undo/redo history.
+	undo undoHistory
+
+	// mark is the other end of the region, with the cursor.
+	mark markState
+
+	// vi is the state of the vi key bindings.
+	vi viState
+
+	// lastCmd is the kind of the previous command processed by
+	// Update; thisCmd is the kind of the command being processed.
+	lastCmd, thisCmd command
 }
 
 // New creates a new model with default settings.
@@ -249,6 +321,10 @@ func New() Model {
 		CharLimit:            defaultCharLimit,
 		MaxHeight:            defaultMaxHeight,
 		MaxWidth:             defaultMaxWidth,
+		MaxKillRingSize:      defaultKillRingSize,
+		UndoMemoryLimit:      defaultUndoMemoryLimit,
+		Indent:               defaultIndent,
+		CommentPrefix:        defaultCommentPrefix,
 		Prompt:               lipgloss.ThickBorder().Left + " ",
 		style:                &blurredStyle,
 		FocusedStyle:         focusedStyle,
@@ -285,6 +361,8 @@ func DefaultStyles() (Style, Style) {
 		Placeholder:      lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
 		Prompt:           lipgloss.NewStyle().Foreground(lipgloss.Color("7")),
 		Text:             lipgloss.NewStyle(),
+		Selection:        lipgloss.NewStyle().Background(lipgloss.AdaptiveColor{Light: "252", Dark: "238"}),
+		Suggestion:       lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
 	}
 	blurred := Style{
 		Base:             lipgloss.NewStyle(),
@@ -295,14 +373,17 @@ func DefaultStyles() (Style, Style) {
 		Placeholder:      lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
 		Prompt:           lipgloss.NewStyle().Foreground(lipgloss.Color("7")),
 		Text:             lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "245", Dark: "7"}),
+		Selection:        lipgloss.NewStyle().Background(lipgloss.AdaptiveColor{Light: "252", Dark: "238"}),
+		Suggestion:       lipgloss.NewStyle().Foreground(lipgloss.Color("240")),
 	}
 
 	return focused, blurred
 }
 
 // SetValue sets the value of the text input.
+// The undo history is preserved.
 func (m *Model) SetValue(s string) {
-	m.Reset()
+	m.resetValue()
 	m.InsertString(s)
 }
 
@@ -562,7 +643,17 @@ func (m *Model) Blur() {
 }
 
 // Reset sets the input to its default state with no input.
+// The undo history is cleared.
 func (m *Model) Reset() {
+	m.resetValue()
+	m.suggestion = ""
+	m.undo = undoHistory{}
+	m.viReset()
+}
+
+// resetValue empties the input.
+func (m *Model) resetValue() {
+	m.DeactivateMark()
 	startCap := m.MaxHeight
 	if startCap <= 0 {
 		startCap = defaultMaxHeight
@@ -570,6 +661,7 @@ func (m *Model) Reset() {
 	m.value = make([][]rune, minHeight, startCap)
 	m.col = 0
 	m.row = 0
+	m.lastCmd, m.thisCmd = cmdOther, cmdOther
 	m.viewport.GotoTop()
 	m.SetCursor(0)
 }
@@ -587,6 +679,7 @@ func (m *Model) san() runeutil.Sanitizer {
 // deleteBeforeCursor deletes all text before the cursor. Returns whether or
 // not the cursor blink should be reset.
 func (m *Model) deleteBeforeCursor() {
+	m.kill(string(m.value[m.row][:m.col]), true /* backward */)
 	m.value[m.row] = m.value[m.row][m.col:]
 	m.SetCursor(0)
 }
@@ -595,6 +688,7 @@ func (m *Model) deleteBeforeCursor() {
 // the cursor blink should be reset. If input is masked delete everything after
 // the cursor so as not to reveal word breaks in the masked input.
 func (m *Model) deleteAfterCursor() {
+	m.kill(string(m.value[m.row][m.col:]), false /* backward */)
 	m.value[m.row] = m.value[m.row][:m.col]
 	m.SetCursor(len(m.value[m.row]))
 }
@@ -650,6 +744,7 @@ func (m *Model) deleteWordLeft() {
 		}
 	}
 
+	m.kill(string(m.value[m.row][m.col:min(oldCol, len(m.value[m.row]))]), true /* backward */)
 	if oldCol > len(m.value[m.row]) {
 		m.value[m.row] = m.value[m.row][:m.col]
 	} else {
@@ -678,6 +773,7 @@ func (m *Model) deleteWordRight() {
 		}
 	}
 
+	m.kill(string(m.value[m.row][oldCol:min(m.col, len(m.value[m.row]))]), false /* backward */)
 	if m.col > len(m.value[m.row]) {
 		m.value[m.row] = m.value[m.row][:oldCol]
 	} else {
@@ -989,86 +1085,30 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch {
-		case key.Matches(msg, m.KeyMap.DeleteAfterCursor):
-			m.col = clamp(m.col, 0, len(m.value[m.row]))
-			if m.col >= len(m.value[m.row]) {
-				m.mergeLineBelow(m.row)
-				break
-			}
-			m.deleteAfterCursor()
-		case key.Matches(msg, m.KeyMap.DeleteBeforeCursor):
-			m.col = clamp(m.col, 0, len(m.value[m.row]))
-			if m.col <= 0 {
-				m.mergeLineAbove(m.row)
-				break
-			}
-			m.deleteBeforeCursor()
-		case key.Matches(msg, m.KeyMap.DeleteCharacterBackward):
-			m.DeleteCharactersBackward(1)
-		case key.Matches(msg, m.KeyMap.DeleteCharacterForward):
-			m.DeleteCharacterForward()
-		case key.Matches(msg, m.KeyMap.DeleteWordBackward):
-			if m.col <= 0 {
-				m.mergeLineAbove(m.row)
+		runes := msg.Runes
+		if m.vi.enabled {
+			if m.handleViKey(msg) {
 				break
 			}
-			m.deleteWordLeft()
-		case key.Matches(msg, m.KeyMap.DeleteWordForward):
-			m.col = clamp(m.col, 0, len(m.value[m.row]))
-			if m.col >= len(m.value[m.row]) {
-				m.mergeLineBelow(m.row)
-				break
-			}
-			m.deleteWordRight()
-		case key.Matches(msg, m.KeyMap.InsertNewline):
-			m.InsertNewline()
-		case key.Matches(msg, m.KeyMap.LineEnd):
-			m.CursorEnd()
-		case key.Matches(msg, m.KeyMap.LineStart):
-			m.CursorStart()
-		case key.Matches(msg, m.KeyMap.CharacterForward):
-			m.characterRight()
-		case key.Matches(msg, m.KeyMap.LineNext):
-			m.CursorDown()
-		case key.Matches(msg, m.KeyMap.WordForward):
-			m.wordRight()
-		case key.Matches(msg, m.KeyMap.Paste):
-			return m, Paste
-		case key.Matches(msg, m.KeyMap.CharacterBackward):
-			m.characterLeft(false /* insideLine */)
-		case key.Matches(msg, m.KeyMap.LinePrevious):
-			m.CursorUp()
-		case key.Matches(msg, m.KeyMap.WordBackward):
-			m.wordLeft()
-		case key.Matches(msg, m.KeyMap.InputBegin):
-			m.moveToBegin()
-		case key.Matches(msg, m.KeyMap.InputEnd):
-			m.moveToEnd()
-		case key.Matches(msg, m.KeyMap.LowercaseWordForward):
-			m.lowercaseRight()
-		case key.Matches(msg, m.KeyMap.UppercaseWordForward):
-			m.uppercaseRight()
-		case key.Matches(msg, m.KeyMap.CapitalizeWordForward):
-			m.capitalizeRight()
-		case key.Matches(msg, m.KeyMap.TransposeCharacterBackward):
-			m.transposeLeft()
-		case key.Matches(msg, m.KeyMap.ToggleOverwriteMode):
-			m.overwrite = !m.overwrite
-
-		default:
-			if !m.overwrite {
-				m.insertRunesFromUserInput(msg.Runes)
-			} else {
-				runes := m.san().Sanitize(msg.Runes)
-				for _, r := range runes {
-					m.overwriteRune(r)
-				}
+			if m.vi.normal {
+				// No text insertion in normal mode.
+				runes = nil
 			}
 		}
+		if cmd := m.handleKey(msg, runes); cmd != nil {
+			return m, cmd
+		}
+
+	case KeySeqMsg:
+		if cmd := m.handleKey(msg, nil); cmd != nil {
+			return m, cmd
+		}
 
 	case pasteMsg:
+		m.lastCmd, m.thisCmd = m.thisCmd, cmdOther
+		before := m.snapshot()
 		m.insertRunesFromUserInput([]rune(msg))
+		m.endChange(before)
 
 	case pasteErrMsg:
 		m.Err = msg
@@ -1091,12 +1131,131 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// handleKey processes a key press, or a sequence of key presses. The
+// runes are inserted if the key does not match any binding. It
+// returns a command if the key requires one.
+func (m *Model) handleKey(msg fmt.Stringer, runes []rune) tea.Cmd {
+	m.lastCmd, m.thisCmd = m.thisCmd, cmdOther
+	before := m.snapshot()
+	defer m.endChange(before)
+
+	switch {
+	case key.Matches(msg, m.KeyMap.SetMark):
+		m.toggleMark()
+	case m.mark.active && key.Matches(msg, m.KeyMap.KillRegion):
+		m.killRegion()
+	case m.mark.active && key.Matches(msg, m.KeyMap.CopyRegion):
+		m.copyRegion()
+	case m.mark.active && key.Matches(msg, m.KeyMap.UppercaseWordForward):
+		m.mapRegion(unicode.ToUpper)
+	case m.mark.active && key.Matches(msg, m.KeyMap.LowercaseWordForward):
+		m.mapRegion(unicode.ToLower)
+	case m.mark.active && key.Matches(msg, m.KeyMap.CapitalizeWordForward):
+		m.capitalizeRegion()
+	case key.Matches(msg, m.KeyMap.IndentRegion):
+		m.indentRegion()
+	case key.Matches(msg, m.KeyMap.DedentRegion):
+		m.dedentRegion()
+	case key.Matches(msg, m.KeyMap.ToggleComment):
+		m.toggleComment()
+	case key.Matches(msg, m.KeyMap.DeleteAfterCursor):
+		m.col = clamp(m.col, 0, len(m.value[m.row]))
+		if m.col >= len(m.value[m.row]) {
+			m.killLineBelow(m.row)
+			break
+		}
+		m.deleteAfterCursor()
+	case key.Matches(msg, m.KeyMap.DeleteBeforeCursor):
+		m.col = clamp(m.col, 0, len(m.value[m.row]))
+		if m.col <= 0 {
+			m.killLineAbove(m.row)
+			break
+		}
+		m.deleteBeforeCursor()
+	case key.Matches(msg, m.KeyMap.DeleteCharacterBackward):
+		m.thisCmd = cmdDeleteChar
+		m.DeleteCharactersBackward(1)
+	case key.Matches(msg, m.KeyMap.DeleteCharacterForward):
+		m.thisCmd = cmdDeleteChar
+		m.DeleteCharacterForward()
+	case key.Matches(msg, m.KeyMap.DeleteWordBackward):
+		if m.col <= 0 {
+			m.killLineAbove(m.row)
+			break
+		}
+		m.deleteWordLeft()
+	case key.Matches(msg, m.KeyMap.DeleteWordForward):
+		m.col = clamp(m.col, 0, len(m.value[m.row]))
+		if m.col >= len(m.value[m.row]) {
+			m.killLineBelow(m.row)
+			break
+		}
+		m.deleteWordRight()
+	case key.Matches(msg, m.KeyMap.InsertNewline):
+		m.InsertNewline()
+	case key.Matches(msg, m.KeyMap.LineEnd):
+		m.CursorEnd()
+	case key.Matches(msg, m.KeyMap.LineStart):
+		m.CursorStart()
+	case key.Matches(msg, m.KeyMap.CharacterForward):
+		m.characterRight()
+	case key.Matches(msg, m.KeyMap.LineNext):
+		m.CursorDown()
+	case key.Matches(msg, m.KeyMap.WordForward):
+		m.wordRight()
+	case key.Matches(msg, m.KeyMap.Paste):
+		return Paste
+	case key.Matches(msg, m.KeyMap.CharacterBackward):
+		m.characterLeft(false /* insideLine */)
+	case key.Matches(msg, m.KeyMap.LinePrevious):
+		m.CursorUp()
+	case key.Matches(msg, m.KeyMap.WordBackward):
+		m.wordLeft()
+	case key.Matches(msg, m.KeyMap.InputBegin):
+		m.moveToBegin()
+	case key.Matches(msg, m.KeyMap.InputEnd):
+		m.moveToEnd()
+	case key.Matches(msg, m.KeyMap.LowercaseWordForward):
+		m.lowercaseRight()
+	case key.Matches(msg, m.KeyMap.UppercaseWordForward):
+		m.uppercaseRight()
+	case key.Matches(msg, m.KeyMap.CapitalizeWordForward):
+		m.capitalizeRight()
+	case key.Matches(msg, m.KeyMap.TransposeCharacterBackward):
+		m.transposeLeft()
+	case key.Matches(msg, m.KeyMap.ToggleOverwriteMode):
+		m.overwrite = !m.overwrite
+	case key.Matches(msg, m.KeyMap.Yank):
+		m.yank()
+	case key.Matches(msg, m.KeyMap.YankPop):
+		m.yankPop()
+
+	default:
+		if len(runes) > 0 {
+			m.thisCmd = cmdInsert
+		}
+		if !m.overwrite {
+			m.insertRunesFromUserInput(runes)
+		} else {
+			runes := m.san().Sanitize(runes)
+			for _, r := range runes {
+				m.overwriteRune(r)
+			}
+		}
+	}
+	return nil
+}
+
 // View renders the text area in its current state.
 func (m Model) View() string {
 	if m.Value() == "" && m.row == 0 && m.col == 0 && m.Placeholder != "" {
 		return m.placeholderView()
 	}
 	m.Cursor.TextStyle = m.style.CursorLine
+	m.maskValue()
+	if m.Highlighter != nil {
+		m.highlights = m.Highlighter(m.value)
+	}
 
 	var s strings.Builder
 	var style lipgloss.Style
@@ -1114,6 +1273,9 @@ func (m Model) View() string {
 			style = m.style.Text
 		}
 
+		// startCol is the column in the line of the first character
+		// of the current wrapped line.
+		startCol := 0
 		for wl, wrappedLine := range wrappedLines {
 			prompt := m.getPromptString(displayLine)
 			prompt = m.style.Prompt.Render(prompt)
@@ -1132,6 +1294,7 @@ func (m Model) View() string {
 				}
 			}
 
+			wrappedLen := len(wrappedLine)
 			strwidth := rw.StringWidth(string(wrappedLine))
 			padding := m.width - strwidth
 			// If the trailing space causes the line to be wider than the
@@ -1146,18 +1309,28 @@ func (m Model) View() string {
 				padding -= m.width - strwidth
 			}
 			if m.row == l && lineInfo.RowOffset == wl {
-				s.WriteString(style.Render(string(wrappedLine[:lineInfo.ColumnOffset])))
+				s.WriteString(m.renderText(style, l, startCol, wrappedLine[:lineInfo.ColumnOffset]))
 				if m.col >= len(line) && lineInfo.CharOffset >= m.width {
 					m.Cursor.SetChar(" ")
 					s.WriteString(m.Cursor.View())
+				} else if ghost := m.suggestionView(l, padding+1); len(ghost) > 0 {
+					// Display the suggestion after the cursor, starting under
+					// the cursor.
+					m.Cursor.SetChar(string(ghost[0]))
+					m.Cursor.TextStyle = m.style.Suggestion.Inherit(style)
+					s.WriteString(style.Render(m.Cursor.View()))
+					s.WriteString(m.style.Suggestion.Inherit(style).Render(string(ghost[1:])))
+					padding -= rw.StringWidth(string(ghost)) - 1
 				} else {
 					m.Cursor.SetChar(string(wrappedLine[lineInfo.ColumnOffset]))
+					m.Cursor.TextStyle = m.textStyle(style, l, m.col)
 					s.WriteString(style.Render(m.Cursor.View()))
-					s.WriteString(style.Render(string(wrappedLine[lineInfo.ColumnOffset+1:])))
+					s.WriteString(m.renderText(style, l, startCol+lineInfo.ColumnOffset+1, wrappedLine[lineInfo.ColumnOffset+1:]))
 				}
 			} else {
-				s.WriteString(style.Render(string(wrappedLine)))
+				s.WriteString(m.renderText(style, l, startCol, wrappedLine))
 			}
+			startCol += wrappedLen
 			s.WriteString(style.Render(strings.Repeat(" ", max(0, padding))))
 			s.WriteRune('\n')
 			newLines++
@@ -37,6 +37,22 @@ func TestTextArea(t *testing.T) {
 				fmt.Fprintf(out, "%+v", s)
 				return nil
 			}),
+			catwalk.WithObserver("killring", func(out io.Writer, m tea.Model) error {
+				for _, e := range m.(*testModel).text.KillRing() {
+					fmt.Fprintf(out, "%q\n", e)
+				}
+				return nil
+			}),
+			catwalk.WithObserver("region", func(out io.Writer, m tea.Model) error {
+				t := &m.(*testModel).text
+				fmt.Fprintf(out, "active: %v, text: %q", t.RegionActive(), t.RegionText())
+				return nil
+			}),
+			catwalk.WithObserver("vi", func(out io.Writer, m tea.Model) error {
+				t := &m.(*testModel).text
+				fmt.Fprintf(out, "normal: %v, pending: %v", t.ViNormalMode(), t.ViPending())
+				return nil
+			}),
 			catwalk.WithObserver("curline", func(out io.Writer, m tea.Model) error {
 				s := m.(*testModel).text.CurrentLine()
 				fmt.Fprintf(out, "%q", s)
@@ -137,6 +153,49 @@ func testCmd(m tea.Model, cmd string, args ...string) (bool, tea.Model, tea.Cmd,
 			return true, t, nil, err
 		}
 		t.text.SetValue(s)
+	case "vi_mode":
+		t.text.SetViMode(true)
+	case "keyseq":
+		var cmd tea.Cmd
+		t.text, cmd = t.text.Update(KeySeqMsg(strings.Join(args, " ")))
+		return true, t, cmd, nil
+	case "highlight":
+		// Highlight the first 3 characters of every line, and the
+		// 2nd to 5th characters of the first line.
+		t.text.Highlighter = func(v [][]rune) [][]StyleSpan {
+			res := make([][]StyleSpan, len(v))
+			for i := range v {
+				res[i] = []StyleSpan{{Start: 0, End: 3, Style: lipgloss.NewStyle().Bold(true)}}
+			}
+			res[0] = append(res[0], StyleSpan{Start: 1, End: 5, Style: lipgloss.NewStyle().Underline(true)})
+			return res
+		}
+	case "echo_password":
+		t.text.EchoMode = EchoPassword
+		t.text.EchoCharacter = '*'
+	case "echo_none":
+		t.text.EchoMode = EchoNone
+	case "wipe":
+		t.text.Wipe()
+	case "suggest":
+		input := strings.Join(args, " ")
+		s, err := strconv.Unquote(input)
+		if err != nil {
+			return true, t, nil, err
+		}
+		t.text.SetSuggestion(s)
+	case "checkpoint":
+		t.text.Checkpoint()
+	case "undo":
+		t.text.Undo()
+	case "redo":
+		t.text.Redo()
+	case "limit_undo_memory":
+		i, err := strconv.Atoi(args[0])
+		if err != nil {
+			return true, t, nil, err
+		}
+		t.text.UndoMemoryLimit = i
 	case "customprompt":
 		t.text.SetPromptFunc(3, func(i int) string {
 			switch i {
@@ -0,0 +1,165 @@
+package textarea
+
+// defaultUndoMemoryLimit is the default maximum amount of memory, in
+// bytes, used by the undo history.
+const defaultUndoMemoryLimit = 1 << 20
+
+// undoState is a snapshot of the value and cursor position.
+type undoState struct {
+	value    [][]rune
+	row, col int
+}
+
+// size estimates the memory used by the snapshot, in bytes.
+func (s *undoState) size() int {
+	const runeSize, lineSize, baseSize = 4, 24, 48
+	sz := baseSize + len(s.value)*lineSize
+	for _, l := range s.value {
+		sz += len(l) * runeSize
+	}
+	return sz
+}
+
+// sameValue returns true iff the snapshot has the specified value.
+func (s *undoState) sameValue(value [][]rune) bool {
+	if len(s.value) != len(value) {
+		return false
+	}
+	for i, l := range s.value {
+		if len(l) != len(value[i]) {
+			return false
+		}
+		for j, r := range l {
+			if r != value[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// undoHistory stores the snapshots for undo and redo.
+type undoHistory struct {
+	// undo is the list of states to return to with undo, most recent
+	// last.
+	undo []undoState
+	// redo is the list of states undone, most recent last.
+	redo []undoState
+	// size is the estimated memory used by the undo list.
+	size int
+}
+
+// snapshot captures the current value and cursor position.
+func (m *Model) snapshot() undoState {
+	v := make([][]rune, len(m.value))
+	for i, l := range m.value {
+		v[i] = append([]rune(nil), l...)
+	}
+	return undoState{value: v, row: m.row, col: m.col}
+}
+
+// restore sets the value and cursor position from a snapshot.
+func (m *Model) restore(s undoState) {
+	m.DeactivateMark()
+	m.value = s.value
+	m.row = clamp(s.row, 0, len(m.value)-1)
+	m.SetCursor(s.col)
+}
+
+// pushUndo adds a state to the undo list, evicting the oldest states
+// if the memory limit is exceeded.
+func (m *Model) pushUndo(s undoState) {
+	u := &m.undo
+	if n := len(u.undo); n > 0 && u.undo[n-1].sameValue(s.value) {
+		// Nothing changed since the last snapshot.
+		return
+	}
+	u.undo = append(u.undo, s)
+	u.size += s.size()
+	for m.UndoMemoryLimit > 0 && u.size > m.UndoMemoryLimit && len(u.undo) > 0 {
+		u.size -= u.undo[0].size()
+		u.undo[0] = undoState{}
+		u.undo = u.undo[1:]
+	}
+}
+
+// endChange is called after a command has been processed, with the
+// state prior to the command. If the value was modified, the region
+// is deactivated and the previous state is recorded for undo.
+func (m *Model) endChange(before undoState) {
+	if !before.sameValue(m.value) {
+		m.DeactivateMark()
+	}
+	m.recordUndo(before)
+}
+
+// recordUndo is called after a command has been processed, with the
+// state prior to the command. If the value was modified, the previous
+// state is added to the undo list. Consecutive insertions, or
+// consecutive character deletions, are grouped together.
+func (m *Model) recordUndo(before undoState) {
+	if before.sameValue(m.value) {
+		return
+	}
+	m.undo.redo = nil
+	if m.vi.inChange {
+		// The vi change is recorded as a whole when it completes.
+		return
+	}
+	if (m.thisCmd == cmdInsert || m.thisCmd == cmdDeleteChar) && m.thisCmd == m.lastCmd {
+		// Same group as the previous command.
+		return
+	}
+	m.pushUndo(before)
+}
+
+// Checkpoint records the current value in the undo history. It is
+// meant for use by the containing editor before it modifies the value
+// via the other methods. It also stops merging consecutive kills and
+// grouping consecutive insertions, and deactivates the region.
+func (m *Model) Checkpoint() {
+	m.DeactivateMark()
+	if m.vi.inChange {
+		// Close the vi change in progress, and start a new one.
+		m.pushUndo(m.vi.cmdBefore)
+		m.vi.cmdBefore = m.snapshot()
+	}
+	m.pushUndo(m.snapshot())
+	m.undo.redo = nil
+	m.thisCmd = cmdOther
+}
+
+// Undo reverts the value to the state prior to the last change.
+// It returns false if there was nothing to undo.
+func (m *Model) Undo() bool {
+	return m.undoRedo(&m.undo.undo, &m.undo.redo)
+}
+
+// Redo reverts the last undo. It returns false if there was nothing
+// to redo.
+func (m *Model) Redo() bool {
+	return m.undoRedo(&m.undo.redo, &m.undo.undo)
+}
+
+func (m *Model) undoRedo(from, to *[]undoState) bool {
+	m.thisCmd = cmdOther
+	// Skip over the states that do not differ from the current value,
+	// so that every undo/redo has a visible effect.
+	for n := len(*from); n > 0 && (*from)[n-1].sameValue(m.value); n = len(*from) {
+		*from = (*from)[:n-1]
+	}
+	n := len(*from)
+	if n == 0 {
+		return false
+	}
+	s := (*from)[n-1]
+	*from = (*from)[:n-1]
+	*to = append(*to, m.snapshot())
+	m.restore(s)
+	// Recompute the memory used by the undo list.
+	m.undo.size = 0
+	for i := range m.undo.undo {
+		m.undo.size += m.undo.undo[i].size()
+	}
+	return true
+}
@@ -0,0 +1,773 @@
+package textarea
+
+import (
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// viState is the state of the modal vi key bindings.
+type viState struct {
+	// enabled is true when the vi key bindings are in use.
+	enabled bool
+	// normal is true in normal (command) mode, false in insert mode.
+	normal bool
+
+	// count is the repeat count entered so far for the current
+	// command or motion.
+	count int
+	// opCount is the count entered before the pending operator.
+	opCount int
+	// op is the pending operator (d, c or y), if any.
+	op rune
+	// argCmd is the pending command waiting for a character argument
+	// (f, F, t, T or r), if any.
+	argCmd rune
+
+	// lastFind is the last character search, repeated by ; and ,.
+	lastFind struct{ cmd, char rune }
+
+	// register is the text deleted or yanked by the last command,
+	// inserted by p and P.
+	register struct {
+		text     string
+		linewise bool
+	}
+
+	// cmdKeys is the keys of the command in progress, after the
+	// initial count. If the command modifies the text, they are
+	// saved in lastChange to be repeated by '.'.
+	cmdKeys []tea.KeyMsg
+	// cmdCount is the count entered before the command in progress.
+	cmdCount int
+	// cmdBefore is the state before the command in progress, for undo.
+	cmdBefore undoState
+	// inChange is true while the keys entered in insert mode are part
+	// of the last change.
+	inChange bool
+
+	// lastChange is the keys of the last command that modified the
+	// text, and lastChangeCount its count.
+	lastChange      []tea.KeyMsg
+	lastChangeCount int
+	// replaying is true while '.' repeats the last change.
+	replaying bool
+}
+
+// viPos is a position in the text.
+type viPos struct{ row, col int }
+
+func (p viPos) before(o viPos) bool {
+	return p.row < o.row || (p.row == o.row && p.col < o.col)
+}
+
+// viReset returns to insert mode and abandons the command in
+// progress, if any.
+func (m *Model) viReset() {
+	m.viCancel()
+	m.vi.normal = false
+	m.vi.inChange = false
+}
+
+// SetViMode enables or disables the vi key bindings. When enabled,
+// the editor starts in insert mode.
+func (m *Model) SetViMode(enabled bool) {
+	if enabled != m.vi.enabled {
+		m.vi = viState{enabled: enabled}
+	}
+}
+
+// ViEnabled returns true iff the vi key bindings are in use.
+func (m *Model) ViEnabled() bool {
+	return m.vi.enabled
+}
+
+// ViNormalMode returns true iff the vi key bindings are in use and
+// the editor is in normal (command) mode.
+func (m *Model) ViNormalMode() bool {
+	return m.vi.enabled && m.vi.normal
+}
+
+// SetViNormalMode switches between the vi normal and insert modes.
+// It is a no-op if the vi key bindings are not in use.
+func (m *Model) SetViNormalMode(normal bool) {
+	if !m.vi.enabled || m.vi.normal == normal {
+		return
+	}
+	m.viCancel()
+	if normal {
+		m.viEnterNormal()
+	} else {
+		m.vi.normal = false
+	}
+}
+
+// ViPending returns true iff a vi command is partially entered.
+func (m *Model) ViPending() bool {
+	return m.vi.normal && (m.vi.count > 0 || len(m.vi.cmdKeys) > 0)
+}
+
+// handleViKey processes a key press with the vi key bindings. It
+// returns false if the key should be processed by the regular key
+// bindings instead.
+func (m *Model) handleViKey(msg tea.KeyMsg) bool {
+	v := &m.vi
+	if !v.normal {
+		if msg.Type == tea.KeyEscape {
+			m.viEnterNormal()
+			return true
+		}
+		if v.inChange {
+			v.cmdKeys = append(v.cmdKeys, msg)
+		}
+		return false
+	}
+
+	// The text may have been modified since the last command, e.g. by
+	// the containing editor.
+	m.viClampCursor()
+	if msg.Type == tea.KeyEscape {
+		m.viCancel()
+		return true
+	}
+	r, ok := viRune(msg)
+	if !ok {
+		// Not a vi command. Cancel the pending command, if any, and
+		// let the regular key bindings apply.
+		m.viCancel()
+		return false
+	}
+
+	m.lastCmd, m.thisCmd = m.thisCmd, cmdOther
+	if v.argCmd == 0 && ((r >= '1' && r <= '9') || (r == '0' && v.count > 0)) {
+		v.count = v.count*10 + int(r-'0')
+		if len(v.cmdKeys) > 0 {
+			v.cmdKeys = append(v.cmdKeys, msg)
+		}
+		return true
+	}
+	if len(v.cmdKeys) == 0 {
+		// Start of a new command.
+		v.cmdBefore = m.snapshot()
+		v.cmdCount = v.count
+	}
+	v.cmdKeys = append(v.cmdKeys, msg)
+	m.viCommand(r)
+	return true
+}
+
+// viRune returns the character entered with the key, if any.
+func viRune(msg tea.KeyMsg) (rune, bool) {
+	switch {
+	case msg.Alt:
+		return 0, false
+	case msg.Type == tea.KeySpace:
+		return ' ', true
+	case msg.Type == tea.KeyRunes && len(msg.Runes) == 1:
+		return msg.Runes[0], true
+	}
+	return 0, false
+}
+
+// viCommand processes a character in normal mode.
+func (m *Model) viCommand(r rune) {
+	v := &m.vi
+
+	if cmd := v.argCmd; cmd != 0 {
+		v.argCmd = 0
+		if cmd == 'r' {
+			m.viReplace(r, m.viTakeCount())
+			m.viEndCommand(true)
+			return
+		}
+		v.lastFind.cmd, v.lastFind.char = cmd, r
+		m.viMotion(cmd, r)
+		return
+	}
+
+	switch r {
+	case 'd', 'c', 'y':
+		switch v.op {
+		case 0:
+			v.op = r
+			v.opCount, v.count = v.count, 0
+		case r:
+			// dd, cc, yy: operate on whole lines.
+			n := m.viTakeCount()
+			m.viOperate(viPos{m.row, 0}, viPos{min(m.row+n-1, len(m.value)-1), 0}, true /* linewise */)
+		default:
+			m.viCancel()
+		}
+
+	case 'f', 'F', 't', 'T', 'r':
+		if r == 'r' && v.op != 0 {
+			m.viCancel()
+			return
+		}
+		v.argCmd = r
+
+	case ';', ',':
+		cmd := v.lastFind.cmd
+		if cmd == 0 {
+			m.viCancel()
+			return
+		}
+		if r == ',' {
+			cmd = map[rune]rune{'f': 'F', 'F': 'f', 't': 'T', 'T': 't'}[cmd]
+		}
+		m.viMotion(cmd, v.lastFind.char)
+
+	case 'h', 'l', ' ', 'w', 'W', 'b', 'B', 'e', 'E', '0', '^', '$', '%', 'j', 'k':
+		m.viMotion(r, 0)
+
+	default:
+		if v.op != 0 {
+			// Not a motion.
+			m.viCancel()
+			return
+		}
+		m.viSimpleCommand(r)
+	}
+}
+
+// viSimpleCommand processes a command that is not a motion and does
+// not take an argument.
+func (m *Model) viSimpleCommand(r rune) {
+	v := &m.vi
+	switch r {
+	case 'x', 'X', 'D', 'C', 's', 'S', 'Y':
+		// Shorthands for an operator and a motion.
+		expand := map[rune]string{
+			'x': "dl", 'X': "dh", 'D': "d$", 'C': "c$", 's': "cl", 'S': "cc", 'Y': "yy",
+		}[r]
+		for _, c := range expand {
+			m.viCommand(c)
+		}
+
+	case 'i', 'a', 'I', 'A', 'o', 'O':
+		m.viTakeCount()
+		line := m.value[m.row]
+		switch r {
+		case 'a':
+			m.SetCursor(min(m.col+1, len(line)))
+		case 'I':
+			m.SetCursor(viFirstNonBlank(line))
+		case 'A':
+			m.CursorEnd()
+		case 'o':
+			m.CursorEnd()
+			m.InsertNewline()
+		case 'O':
+			m.CursorStart()
+			m.InsertNewline()
+			m.row--
+		}
+		m.viEnterInsert()
+
+	case 'p', 'P':
+		m.viPut(r == 'P', m.viTakeCount())
+		m.viEndCommand(true)
+
+	case '~':
+		n := m.viTakeCount()
+		line := m.value[m.row]
+		end := min(m.col+n, len(line))
+		for i := m.col; i < end; i++ {
+			if unicode.IsUpper(line[i]) {
+				line[i] = unicode.ToLower(line[i])
+			} else {
+				line[i] = unicode.ToUpper(line[i])
+			}
+		}
+		m.SetCursor(end)
+		m.viEndCommand(true)
+
+	case 'u':
+		for n := m.viTakeCount(); n > 0; n-- {
+			m.Undo()
+		}
+		m.viEndCommand(false)
+
+	case '.':
+		count := m.viTakeCount()
+		if v.cmdCount == 0 {
+			count = v.lastChangeCount
+		}
+		v.cmdKeys = nil
+		m.viRepeat(count)
+
+	default:
+		m.viCancel()
+	}
+}
+
+// viTakeCount returns the effective count of the command in progress
+// and resets it.
+func (m *Model) viTakeCount() int {
+	v := &m.vi
+	n := max(v.opCount, 1) * max(v.count, 1)
+	v.opCount, v.count = 0, 0
+	return n
+}
+
+// viCancel abandons the command in progress.
+func (m *Model) viCancel() {
+	v := &m.vi
+	v.count, v.opCount, v.op, v.argCmd = 0, 0, 0, 0
+	v.cmdKeys = nil
+}
+
+// viEndCommand completes the command in progress. If changed is
+// set, the command is recorded as the last change for '.', and as a
+// single step for undo.
+func (m *Model) viEndCommand(changed bool) {
+	v := &m.vi
+	if changed && !v.replaying {
+		v.lastChange = v.cmdKeys
+		v.lastChangeCount = v.cmdCount
+	}
+	v.cmdKeys = nil
+	v.count, v.opCount, v.op, v.argCmd = 0, 0, 0, 0
+	if changed {
+		m.thisCmd = cmdOther
+		m.endChange(v.cmdBefore)
+	}
+	if v.normal {
+		m.viClampCursor()
+	}
+}
+
+// viEnterInsert switches to insert mode as part of the command in
+// progress. The text entered until the return to normal mode is
+// part of the command.
+func (m *Model) viEnterInsert() {
+	v := &m.vi
+	v.count, v.opCount, v.op, v.argCmd = 0, 0, 0, 0
+	v.normal = false
+	v.inChange = true
+}
+
+// viEnterNormal switches to normal mode.
+func (m *Model) viEnterNormal() {
+	v := &m.vi
+	if v.inChange {
+		v.inChange = false
+		m.viEndCommand(true)
+	}
+	v.normal = true
+	// As in vi, the cursor moves back onto the last character inserted.
+	if m.col > 0 {
+		m.SetCursor(m.col - 1)
+	}
+}
+
+// viClampCursor ensures that the cursor is on a character, as
+// required in normal mode.
+func (m *Model) viClampCursor() {
+	if n := len(m.value[m.row]); m.col >= n {
+		m.SetCursor(max(0, n-1))
+	}
+}
+
+// viRepeat repeats the last change.
+func (m *Model) viRepeat(count int) {
+	v := &m.vi
+	if len(v.lastChange) == 0 {
+		return
+	}
+	v.replaying = true
+	defer func() { v.replaying = false }()
+	v.count = count
+	for _, k := range v.lastChange {
+		if !m.handleViKey(k) {
+			m.handleKey(k, k.Runes)
+		}
+	}
+	if !v.normal {
+		m.viEnterNormal()
+	}
+}
+
+// viMotion moves the cursor, or applies the pending operator, using
+// the specified motion.
+func (m *Model) viMotion(motion, char rune) {
+	v := &m.vi
+	op := v.op
+	n := m.viTakeCount()
+	if op == 'c' && (motion == 'w' || motion == 'W') {
+		// As in vi, cw changes to the end of the word.
+		if r := m.viCharAt(viPos{m.row, m.col}); r != 0 && !unicode.IsSpace(r) {
+			motion = map[rune]rune{'w': 'e', 'W': 'E'}[motion]
+		}
+	}
+	target, inclusive, linewise, ok := m.viTarget(motion, char, n, op != 0)
+	if !ok {
+		m.viCancel()
+		return
+	}
+	if op == 0 {
+		m.row = target.row
+		m.SetCursor(target.col)
+		m.viEndCommand(false)
+		return
+	}
+
+	start := viPos{m.row, m.col}
+	end := target
+	if end.before(start) {
+		start, end = end, start
+	}
+	if (motion == 'w' || motion == 'W') && end.row > start.row {
+		// The last word moved over is at the end of a line: the
+		// operator stops at the end of that line.
+		end = viPos{end.row - 1, len(m.value[end.row-1])}
+	}
+	if inclusive {
+		end.col = min(end.col+1, len(m.value[end.row]))
+	}
+	m.viOperate(start, end, linewise)
+}
+
+// viTarget computes the position reached by a motion, repeated n
+// times.
+func (m *Model) viTarget(
+	motion, char rune, n int, withOp bool,
+) (target viPos, inclusive, linewise, ok bool) {
+	p := viPos{m.row, m.col}
+	line := m.value[p.row]
+	switch motion {
+	case 'h':
+		p.col = max(0, p.col-n)
+		return p, false, false, p.col != m.col
+	case 'l', ' ':
+		maxCol := len(line) - 1
+		if withOp {
+			maxCol = len(line)
+		}
+		p.col = min(p.col+n, maxCol)
+		return p, false, false, p.col > m.col
+	case '0':
+		return viPos{p.row, 0}, false, false, true
+	case '^':
+		return viPos{p.row, viFirstNonBlank(line)}, false, false, true
+	case '$':
+		row := min(p.row+n-1, len(m.value)-1)
+		return viPos{row, max(0, len(m.value[row])-1)}, true, false, true
+	case 'j':
+		if p.row+n >= len(m.value) {
+			return p, false, true, false
+		}
+		return viPos{p.row + n, p.col}, false, true, true
+	case 'k':
+		if p.row-n < 0 {
+			return p, false, true, false
+		}
+		return viPos{p.row - n, p.col}, false, true, true
+	case 'w', 'W':
+		big := motion == 'W'
+		for ; n > 0; n-- {
+			if c := viClass(m.viCharAt(p), big); c != 0 {
+				for m.viMoveIf(&p, func(r rune) bool { return viClass(r, big) == c }) {
+				}
+			}
+			for m.viMoveIf(&p, func(r rune) bool { return r != 0 && viClass(r, big) == 0 }) {
+			}
+		}
+		return p, false, false, true
+	case 'e', 'E':
+		big := motion == 'E'
+		for ; n > 0; n-- {
+			q, more := m.viNext(p)
+			if !more {
+				break
+			}
+			for viClass(m.viCharAt(q), big) == 0 {
+				if q, more = m.viNext(q); !more {
+					return p, true, false, true
+				}
+			}
+			c := viClass(m.viCharAt(q), big)
+			for {
+				next, more := m.viNext(q)
+				if !more || viClass(m.viCharAt(next), big) != c {
+					break
+				}
+				q = next
+			}
+			p = q
+		}
+		return p, true, false, true
+	case 'b', 'B':
+		big := motion == 'B'
+		for ; n > 0; n-- {
+			q, more := m.viPrev(p)
+			if !more {
+				break
+			}
+			for viClass(m.viCharAt(q), big) == 0 {
+				if q, more = m.viPrev(q); !more {
+					return q, false, false, true
+				}
+			}
+			c := viClass(m.viCharAt(q), big)
+			for {
+				prev, more := m.viPrev(q)
+				if !more || viClass(m.viCharAt(prev), big) != c {
+					break
+				}
+				q = prev
+			}
+			p = q
+		}
+		return p, false, false, true
+	case 'f', 't':
+		col := p.col
+		for ; n > 0; n-- {
+			i := col + 1
+			for ; i < len(line) && line[i] != char; i++ {
+			}
+			if i >= len(line) {
+				return p, false, false, false
+			}
+			col = i
+		}
+		if motion == 't' {
+			col--
+		}
+		return viPos{p.row, col}, true, false, true
+	case 'F', 'T':
+		col := p.col
+		for ; n > 0; n-- {
+			i := col - 1
+			for ; i >= 0 && line[i] != char; i-- {
+			}
+			if i < 0 {
+				return p, false, false, false
+			}
+			col = i
+		}
+		if motion == 'T' {
+			col++
+		}
+		return viPos{p.row, col}, false, false, true
+	case '%':
+		target, ok := m.viMatchBracket(p)
+		return target, true, false, ok
+	}
+	return p, false, false, false
+}
+
+// viOperate applies the pending operator to the text between the
+// specified positions.
+func (m *Model) viOperate(start, end viPos, linewise bool) {
+	v := &m.vi
+	op := v.op
+	v.op = 0
+	if linewise {
+		var buf strings.Builder
+		for row := start.row; row <= end.row; row++ {
+			buf.WriteString(string(m.value[row]))
+			buf.WriteByte('\n')
+		}
+		m.viSetRegister(buf.String(), true)
+		switch op {
+		case 'd':
+			if end.row < len(m.value)-1 {
+				m.deleteRange(start.row, 0, end.row+1, 0)
+			} else if start.row > 0 {
+				m.deleteRange(start.row-1, len(m.value[start.row-1]), end.row, len(m.value[end.row]))
+			} else {
+				m.deleteRange(start.row, 0, end.row, len(m.value[end.row]))
+			}
+			m.SetCursor(viFirstNonBlank(m.value[m.row]))
+		case 'c':
+			m.deleteRange(start.row, 0, end.row, len(m.value[end.row]))
+			m.viEnterInsert()
+			return
+		case 'y':
+			m.row = start.row
+			m.SetCursor(m.col)
+		}
+		m.viEndCommand(op != 'y')
+		return
+	}
+
+	m.viSetRegister(m.textRange(start.row, start.col, end.row, end.col), false)
+	switch op {
+	case 'd':
+		m.deleteRange(start.row, start.col, end.row, end.col)
+	case 'c':
+		m.deleteRange(start.row, start.col, end.row, end.col)
+		m.viEnterInsert()
+		return
+	case 'y':
+		m.row = start.row
+		m.SetCursor(start.col)
+	}
+	m.viEndCommand(op != 'y')
+}
+
+// viSetRegister stores deleted or yanked text for use by p/P. The
+// text is also added to the kill ring.
+func (m *Model) viSetRegister(s string, linewise bool) {
+	if m.EchoMode != EchoNormal {
+		// Note: the text of secrets is not preserved.
+		return
+	}
+	m.vi.register.text = s
+	m.vi.register.linewise = linewise
+	if s != "" {
+		m.pushKill(s)
+	}
+}
+
+// viPut inserts the text from the register n times, after the cursor
+// or before it if before is set.
+func (m *Model) viPut(before bool, n int) {
+	reg := m.vi.register
+	if reg.text == "" {
+		return
+	}
+	text := strings.Repeat(reg.text, n)
+	if reg.linewise {
+		text = strings.TrimSuffix(text, "\n")
+		row := m.row
+		if before {
+			m.CursorStart()
+			m.InsertString(text + "\n")
+		} else {
+			m.CursorEnd()
+			m.InsertString("\n" + text)
+			row++
+		}
+		m.row = row
+		m.SetCursor(viFirstNonBlank(m.value[row]))
+		return
+	}
+	if !before && len(m.value[m.row]) > 0 {
+		m.SetCursor(m.col + 1)
+	}
+	m.InsertString(text)
+	// The cursor ends on the last character inserted.
+	m.SetCursor(m.col - 1)
+}
+
+// viReplace replaces n characters under the cursor by r.
+func (m *Model) viReplace(r rune, n int) {
+	line := m.value[m.row]
+	if m.col+n > len(line) {
+		return
+	}
+	for i := m.col; i < m.col+n; i++ {
+		line[i] = r
+	}
+	m.SetCursor(m.col + n - 1)
+}
+
+// viMatchBracket finds the bracket matching the first bracket at or
+// after the specified position on the same line.
+func (m *Model) viMatchBracket(p viPos) (viPos, bool) {
+	const brackets = "()[]{}"
+	line := m.value[p.row]
+	for ; p.col < len(line) && !strings.ContainsRune(brackets, line[p.col]); p.col++ {
+	}
+	if p.col >= len(line) {
+		return p, false
+	}
+	open := line[p.col]
+	idx := strings.IndexRune(brackets, open)
+	forward := idx%2 == 0
+	match := rune(brackets[idx^1])
+	step := m.viNext
+	if !forward {
+		step = m.viPrev
+	}
+	depth := 0
+	for q, more := p, true; more; q, more = step(q) {
+		switch m.viCharAt(q) {
+		case open:
+			depth++
+		case match:
+			depth--
+			if depth == 0 {
+				return q, true
+			}
+		}
+	}
+	return p, false
+}
+
+// viCharAt returns the character at the specified position: '\n'
+// at the end of a line that is not the last, and 0 at the end of the
+// text.
+func (m *Model) viCharAt(p viPos) rune {
+	line := m.value[p.row]
+	if p.col < len(line) {
+		return line[p.col]
+	}
+	if p.row < len(m.value)-1 {
+		return '\n'
+	}
+	return 0
+}
+
+// viNext returns the position after p, including the positions of
+// the newlines. It returns false at the end of the text.
+func (m *Model) viNext(p viPos) (viPos, bool) {
+	if p.col < len(m.value[p.row]) {
+		return viPos{p.row, p.col + 1}, true
+	}
+	if p.row < len(m.value)-1 {
+		return viPos{p.row + 1, 0}, true
+	}
+	return p, false
+}
+
+// viPrev returns the position before p, including the positions of
+// the newlines. It returns false at the start of the text.
+func (m *Model) viPrev(p viPos) (viPos, bool) {
+	if p.col > 0 {
+		return viPos{p.row, p.col - 1}, true
+	}
+	if p.row > 0 {
+		return viPos{p.row - 1, len(m.value[p.row-1])}, true
+	}
+	return p, false
+}
+
+// viMoveIf advances p by one position if the character at p
+// satisfies the predicate. It returns true if p was advanced.
+func (m *Model) viMoveIf(p *viPos, pred func(r rune) bool) bool {
+	if !pred(m.viCharAt(*p)) {
+		return false
+	}
+	next, ok := m.viNext(*p)
+	*p = next
+	return ok
+}
+
+// viClass classifies characters for the word motions: 0 for spaces,
+// 1 for word characters, 2 for punctuation. If big is set, all the
+// non-space characters are in the same class.
+func viClass(r rune, big bool) int {
+	switch {
+	case r == 0 || unicode.IsSpace(r):
+		return 0
+	case big || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// viFirstNonBlank returns the column of the first non-blank
+// character in the line.
+func viFirstNonBlank(line []rune) int {
+	for i, r := range line {
+		if !unicode.IsSpace(r) {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+package editline_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knz/bubbline"
+	"github.com/knz/bubbline/editline"
+	"github.com/muesli/termenv"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestGetLineInputOutput(t *testing.T) {
+	// Run multiple editors concurrently, each with its own streams.
+	const numEditors = 3
+	var wg sync.WaitGroup
+	for i := 0; i < numEditors; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			inR, inW := io.Pipe()
+			var out syncBuffer
+			m := bubbline.New()
+			m.SetInputOutput(inR, &out)
+			m.SetColorProfile(termenv.Ascii)
+			m.Resize(40, 10)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			go func() {
+				_, _ = io.WriteString(inW, "hello\r")
+			}()
+			val, err := m.GetLineContext(ctx)
+			_ = inW.Close()
+			if err != nil {
+				t.Errorf("%d: unexpected error: %v", i, err)
+				return
+			}
+			if val != "hello" {
+				t.Errorf("%d: expected %q, got %q", i, "hello", val)
+			}
+			// The editor is displayed on the output, without colors.
+			if o := out.String(); !strings.Contains(o, "M-? toggle key help") {
+				t.Errorf("%d: expected uncolored key help in output, got:\n%q", i, o)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestGetLineContextCancel(t *testing.T) {
+	inR, inW := io.Pipe()
+	defer inW.Close()
+	var out syncBuffer
+	m := bubbline.New()
+	m.SetInputOutput(inR, &out)
+	m.Resize(40, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// Cancel the context upon the tab key, after the preceding input
+	// has been processed.
+	m.AutoComplete = func(v [][]rune, line, col int) (string, editline.Completions) {
+		cancel()
+		return "", nil
+	}
+	go func() {
+		_, _ = io.WriteString(inW, "hello\t")
+	}()
+	val, err := m.GetLineContext(ctx)
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if val != "hello" || m.Value() != "hello" {
+		t.Errorf("expected input to be preserved, got %q / %q", val, m.Value())
+	}
+}
+
+func TestGetLineContextDeadline(t *testing.T) {
+	inR, inW := io.Pipe()
+	defer inW.Close()
+	var out syncBuffer
+	m := bubbline.New()
+	m.SetInputOutput(inR, &out)
+	m.Resize(40, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	go func() {
+		_, _ = io.WriteString(inW, "hello")
+	}()
+	if _, err := m.GetLineContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package editline
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/knz/bubbline/editline/internal/textarea"
+)
+
+// forEachBinding calls fn on every key binding in the key map,
+// including those of the embedded textarea key map.
+func forEachBinding(km interface{}, fn func(b *key.Binding)) {
+	v := reflect.ValueOf(km).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		switch b := f.Addr().Interface().(type) {
+		case *key.Binding:
+			fn(b)
+		default:
+			if f.Kind() == reflect.Struct {
+				forEachBinding(f.Addr().Interface(), fn)
+			}
+		}
+	}
+}
+
+// isKeySeqPrefix returns true if the specified keys are the beginning
+// of a multi-key sequence in one of the enabled key bindings.
+func (m *Model) isKeySeqPrefix(keys string) (found bool) {
+	forEachBinding(&m.KeyMap, func(b *key.Binding) {
+		if !b.Enabled() {
+			return
+		}
+		for _, k := range b.Keys() {
+			if strings.HasPrefix(k, keys+" ") {
+				found = true
+			}
+		}
+	})
+	return found
+}
+
+// processKeySeq accumulates key presses that form the prefix of a
+// multi-key sequence. It returns consumed set to true if the key was
+// added to the prefix. Otherwise, it returns either the key itself, or
+// a textarea.KeySeqMsg if the key completes a sequence.
+func (m *Model) processKeySeq(msg tea.KeyMsg) (res tea.Msg, consumed bool) {
+	keys := msg.String()
+	if m.keySeqPrefix != "" {
+		keys = m.keySeqPrefix + " " + keys
+	}
+	if m.isKeySeqPrefix(keys) {
+		m.keySeqPrefix = keys
+		return nil, true
+	}
+	if m.keySeqPrefix == "" {
+		return msg, false
+	}
+	m.keySeqPrefix = ""
+	return textarea.KeySeqMsg(keys), false
+}
+
+// asKey returns the key or key sequence in the message, if any.
+func asKey(msg tea.Msg) (fmt.Stringer, bool) {
+	switch k := msg.(type) {
+	case tea.KeyMsg:
+		return k, true
+	case textarea.KeySeqMsg:
+		return k, true
+	}
+	return nil, false
+}
@@ -0,0 +1,47 @@
+package editline
+
+import "github.com/charmbracelet/lipgloss"
+
+// Renderer returns a copy of the style where all the component
+// styles are bound to the given lipgloss renderer.
+func (s Style) Renderer(r *lipgloss.Renderer) Style {
+	s.Editor = s.Editor.Renderer(r)
+	si := &s.SearchInput
+	si.PromptStyle = si.PromptStyle.Renderer(r)
+	si.TextStyle = si.TextStyle.Renderer(r)
+	si.BackgroundStyle = si.BackgroundStyle.Renderer(r)
+	si.PlaceholderStyle = si.PlaceholderStyle.Renderer(r)
+	si.CursorStyle = si.CursorStyle.Renderer(r)
+	s.SearchMatch = s.SearchMatch.Renderer(r)
+	return s
+}
+
+// SetRenderer configures the editor to display using the given
+// lipgloss renderer instead of the default one, which is bound to
+// the process' standard output. This makes the colors suitable for
+// the terminal the editor is actually displayed on, e.g. over a
+// network connection.
+//
+// The styles configured at the time of the call are bound to the
+// renderer. Styles configured afterwards, including those returned
+// by a Highlighter, should be created using r.NewStyle().
+func (m *Model) SetRenderer(r *lipgloss.Renderer) {
+	m.FocusedStyle = m.FocusedStyle.Renderer(r)
+	m.BlurredStyle = m.BlurredStyle.Renderer(r)
+	m.text.Cursor.Style = m.text.Cursor.Style.Renderer(r)
+	m.text.Cursor.TextStyle = m.text.Cursor.TextStyle.Renderer(r)
+	m.hctrl.pattern.Cursor.Style = m.hctrl.pattern.Cursor.Style.Renderer(r)
+	m.hctrl.pattern.Cursor.TextStyle = m.hctrl.pattern.Cursor.TextStyle.Renderer(r)
+	m.hctrl.pattern.CompletionStyle = m.hctrl.pattern.CompletionStyle.Renderer(r)
+
+	hs := &m.help.Styles
+	hs.Ellipsis = hs.Ellipsis.Renderer(r)
+	hs.ShortKey = hs.ShortKey.Renderer(r)
+	hs.ShortDesc = hs.ShortDesc.Renderer(r)
+	hs.ShortSeparator = hs.ShortSeparator.Renderer(r)
+	hs.FullKey = hs.FullKey.Renderer(r)
+	hs.FullDesc = hs.FullDesc.Renderer(r)
+	hs.FullSeparator = hs.FullSeparator.Renderer(r)
+
+	m.completions.SetRenderer(r)
+}
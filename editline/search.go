@@ -0,0 +1,199 @@
+package editline
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/knz/bubbline/complete"
+	"github.com/knz/bubbline/editline/internal/textarea"
+)
+
+// SearchMode determines how the history search pattern is
+// interpreted.
+type SearchMode int
+
+const (
+	// SearchGlob interprets the pattern as a glob: '*' matches any
+	// sequence of characters, '?' matches any single character, and
+	// '[...]' matches a character class. The syntax is otherwise that
+	// of filepath.Match. The pattern can match anywhere in the entry.
+	SearchGlob SearchMode = iota
+	// SearchSubstring looks for the pattern as-is, so that no
+	// character is special.
+	SearchSubstring
+	// SearchRegexp interprets the pattern as a regular expression, in
+	// the syntax of the regexp package.
+	SearchRegexp
+)
+
+// String implements the fmt.Stringer interface.
+func (s SearchMode) String() string {
+	switch s {
+	case SearchGlob:
+		return "glob"
+	case SearchSubstring:
+		return "substring"
+	case SearchRegexp:
+		return "regexp"
+	default:
+		return "unknown"
+	}
+}
+
+// searchExpr returns the regular expression equivalent to the
+// search pattern. The result is not validated.
+func (m *Model) searchExpr() string {
+	pat := m.hctrl.pattern.Value()
+	var expr string
+	switch m.SearchMode {
+	case SearchSubstring:
+		expr = regexp.QuoteMeta(pat)
+	case SearchRegexp:
+		expr = pat
+	default:
+		expr = globToRegexp(pat)
+	}
+	if !m.CaseSensitiveSearch {
+		expr = "(?i)" + expr
+	}
+	return expr
+}
+
+// globToRegexp translates a glob pattern into a regular expression.
+// An invalid glob, e.g. with an unterminated character class, is
+// translated into an invalid regular expression.
+func globToRegexp(pat string) string {
+	var buf strings.Builder
+	inClass := false
+	for i := 0; i < len(pat); {
+		r, sz := utf8.DecodeRuneInString(pat[i:])
+		i += sz
+		switch {
+		case r == '\\':
+			if i == len(pat) {
+				// Trailing backslash: invalid.
+				buf.WriteByte('\\')
+				continue
+			}
+			r, sz = utf8.DecodeRuneInString(pat[i:])
+			i += sz
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		case inClass:
+			switch {
+			case r == ']':
+				inClass = false
+				buf.WriteRune(r)
+			case r == '-':
+				buf.WriteRune(r)
+			default:
+				buf.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		case r == '*':
+			buf.WriteString(`(?s:.*)`)
+		case r == '?':
+			buf.WriteString(`(?s:.)`)
+		case r == '[':
+			inClass = true
+			buf.WriteRune(r)
+			if i < len(pat) && (pat[i] == '^' || pat[i] == '!') {
+				buf.WriteByte('^')
+				i++
+			}
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return buf.String()
+}
+
+// findMatch returns the position of the match for re in entry, if
+// any: the last match for a backward search and the first match
+// otherwise.
+func findMatch(re *regexp.Regexp, entry string, forward bool) []int {
+	if forward {
+		return re.FindStringIndex(entry)
+	}
+	all := re.FindAllStringIndex(entry, -1)
+	if len(all) == 0 {
+		return nil
+	}
+	return all[len(all)-1]
+}
+
+// setSearchPrompt displays the search prompt for the current search
+// direction.
+func (m *Model) setSearchPrompt(found, valid bool) {
+	switch {
+	case !valid && m.hctrl.c.forward:
+		m.hctrl.pattern.Prompt = m.SearchForwardPromptInvalid
+	case !valid:
+		m.hctrl.pattern.Prompt = m.SearchPromptInvalid
+	case !found && m.hctrl.c.forward:
+		m.hctrl.pattern.Prompt = m.SearchForwardPromptNotFound
+	case !found:
+		m.hctrl.pattern.Prompt = m.SearchPromptNotFound
+	case m.hctrl.c.forward:
+		m.hctrl.pattern.Prompt = m.SearchForwardPrompt
+	default:
+		m.hctrl.pattern.Prompt = m.SearchPrompt
+	}
+}
+
+// setSearchMatch records the position of the text matched by the
+// search pattern in the entry, in bytes, for highlighting.
+func (m *Model) setSearchMatch(entry string, loc []int) {
+	start := utf8.RuneCountInString(entry[:loc[0]])
+	end := start + utf8.RuneCountInString(entry[loc[0]:loc[1]])
+	m.hctrl.c.matches = [][2]int{{start, end}}
+}
+
+// setPickerMatches records the position of the characters matched by
+// the search pattern in the entry selected in the history picker, for
+// highlighting.
+func (m *Model) setPickerMatches(entry string) {
+	m.hctrl.c.matches = nil
+	// The newline characters are replaced in the same way as in the
+	// picker, to get the same positions.
+	_, pos, _ := complete.FuzzyMatch(m.hctrl.pattern.Value(), strings.ReplaceAll(entry, "\n", "↵"))
+	for _, p := range pos {
+		m.hctrl.c.matches = append(m.hctrl.c.matches, [2]int{p, p + 1})
+	}
+}
+
+// searchHighlighter returns a highlighter that adds the highlighting
+// of the text matched by the search pattern to that of the text
+// highlighter.
+func (m *Model) searchHighlighter() textarea.HighlightFn {
+	base, matches, style := m.text.Highlighter, m.hctrl.c.matches, m.FocusedStyle.SearchMatch
+	return func(value [][]rune) [][]StyleSpan {
+		var spans [][]StyleSpan
+		if base != nil {
+			spans = base(value)
+		}
+		if len(spans) < len(value) {
+			spans = append(spans[:len(spans):len(spans)], make([][]StyleSpan, len(value)-len(spans))...)
+		}
+		// The matches are positions in the entire value, where the
+		// newline at the end of each line counts as one character.
+		rowStart := 0
+		for row, line := range value {
+			rowEnd := rowStart + len(line)
+			for _, match := range matches {
+				start, end := max(match[0], rowStart), min(match[1], rowEnd)
+				if start < end {
+					// Do not modify the spans returned by the text
+					// highlighter in-place.
+					s := spans[row]
+					spans[row] = append(s[:len(s):len(s)], StyleSpan{
+						Start: start - rowStart,
+						End:   end - rowStart,
+						Style: style,
+					})
+				}
+			}
+			rowStart = rowEnd + 1
+		}
+		return spans
+	}
+}
@@ -0,0 +1,71 @@
+package editline
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SuggestFn is called after every change to the input, when the
+// cursor is at the end of the input, to produce an inline suggestion.
+// The callback is provided the text of the input, and returns the
+// text to suggest after the input, or the empty string if there is no
+// suggestion.
+type SuggestFn func(entireInput [][]rune) string
+
+// maxSuggestionEntries is the number of most recent history entries
+// considered by historySuggestion. This bounds the work done upon
+// every key press when the history is large.
+const maxSuggestionEntries = 1000
+
+// historySuggestion suggests the remainder of the most recent history
+// entry that starts with the input.
+func (m *Model) historySuggestion(entireInput [][]rune) string {
+	lines := make([]string, len(entireInput))
+	for i, l := range entireInput {
+		lines[i] = string(l)
+	}
+	input := strings.Join(lines, "\n")
+	n := m.history.Len()
+	for i := n - 1; i >= max(n-maxSuggestionEntries, 0); i-- {
+		// The entry must be longer than the input.
+		if e := m.historyAt(i); len(e.Text) > len(input) && strings.HasPrefix(e.Text, input) {
+			return e.Text[len(input):]
+		}
+	}
+	return ""
+}
+
+// updateSuggestion recomputes the inline suggestion for the current
+// input.
+func (m *Model) updateSuggestion() {
+	suggestion := ""
+	if m.AutoSuggest && !m.secret() &&
+		!m.currentlySearching() && !m.showCompletions &&
+		!m.text.ViNormalMode() && !m.text.EmptyValue() && m.text.AtEndOfInput() {
+		suggest := m.Suggest
+		if suggest == nil {
+			suggest = m.historySuggestion
+		}
+		suggestion = suggest(m.text.ValueRunes())
+	}
+	m.text.SetSuggestion(suggestion)
+}
+
+// acceptSuggestion inserts the current suggestion, or only its first
+// word if wordOnly is set.
+func (m *Model) acceptSuggestion(wordOnly bool) {
+	s := m.text.Suggestion()
+	if wordOnly {
+		r := []rune(s)
+		i := 0
+		for i < len(r) && unicode.IsSpace(r[i]) {
+			i++
+		}
+		for i < len(r) && !unicode.IsSpace(r[i]) {
+			i++
+		}
+		s = string(r[:i])
+	}
+	m.text.Checkpoint()
+	m.text.InsertString(s)
+}
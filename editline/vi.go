@@ -0,0 +1,89 @@
+package editline
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/knz/bubbline/editline/internal/textarea"
+)
+
+// EditMode is the style of key bindings used by the editor.
+type EditMode int
+
+const (
+	// EmacsMode uses the Emacs-style key bindings. This is the default.
+	EmacsMode EditMode = iota
+	// ViInsertMode is the insert mode of the modal vi key bindings.
+	ViInsertMode
+	// ViNormalMode is the normal (command) mode of the modal vi key
+	// bindings.
+	ViNormalMode
+)
+
+// String implements the fmt.Stringer interface.
+func (e EditMode) String() string {
+	switch e {
+	case ViInsertMode:
+		return "vi-insert"
+	case ViNormalMode:
+		return "vi-normal"
+	default:
+		return "emacs"
+	}
+}
+
+// SetEditMode changes the editing mode immediately. The mode is also
+// used at the start of subsequent inputs.
+func (m *Model) SetEditMode(mode EditMode) {
+	m.EditMode = mode
+	m.applyEditMode()
+	m.updatePrompt()
+}
+
+// CurrentEditMode returns the current editing mode. In vi mode, this
+// reflects whether the editor is in insert or normal mode.
+func (m *Model) CurrentEditMode() EditMode {
+	switch {
+	case !m.text.ViEnabled():
+		return EmacsMode
+	case m.text.ViNormalMode():
+		return ViNormalMode
+	default:
+		return ViInsertMode
+	}
+}
+
+// applyEditMode configures the editor for the initial editing mode.
+func (m *Model) applyEditMode() {
+	m.text.SetViMode(m.EditMode != EmacsMode)
+	m.text.SetViNormalMode(m.EditMode == ViNormalMode)
+}
+
+// translateViKey translates the keys of the vi normal mode that
+// navigate the history into the equivalent key bindings, so that they
+// are processed by the editor in the same way.
+func (m *Model) translateViKey(msg tea.Msg) tea.Msg {
+	if !m.text.ViNormalMode() || m.text.ViPending() {
+		return msg
+	}
+	k, ok := msg.(tea.KeyMsg)
+	if !ok || k.Alt || k.Type != tea.KeyRunes || len(k.Runes) != 1 {
+		return msg
+	}
+	var b key.Binding
+	switch k.Runes[0] {
+	case 'k', '-':
+		b = m.KeyMap.LinePrevious
+	case 'j', '+':
+		b = m.KeyMap.LineNext
+	case '/':
+		b = m.KeyMap.SearchBackward
+	case '?':
+		b = m.KeyMap.SearchForward
+	default:
+		return msg
+	}
+	if !b.Enabled() || len(b.Keys()) == 0 {
+		return msg
+	}
+	return textarea.KeySeqMsg(b.Keys()[0])
+}
@@ -0,0 +1,94 @@
+package editline
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/knz/bubbline/computil"
+)
+
+// TokenizeFn is the type of the Tokenizer callback. It splits a
+// history entry into words.
+type TokenizeFn func(entry string) []string
+
+// yankArgState is the state of the YankLastArg command.
+type yankArgState struct {
+	// active is true while YankLastArg is pressed repeatedly.
+	active bool
+	// entry is the position in the history of the entry the word
+	// inserted last was taken from.
+	entry int
+	// word is the position of the word to insert, or -1 for the last
+	// word.
+	word int
+	// inserted is the length in runes of the word inserted last.
+	inserted int
+	// arg is the numeric argument entered with DigitArgument, or -1
+	// if there is none.
+	arg int
+}
+
+// updateYankArgState forgets the state of the YankLastArg command
+// when another key is pressed.
+func (m *Model) updateYankArgState(k fmt.Stringer) {
+	if !key.Matches(k, m.KeyMap.YankLastArg) {
+		m.yankArg.active = false
+	}
+	if !key.Matches(k, m.KeyMap.YankLastArg, m.KeyMap.DigitArgument) {
+		m.yankArg.arg = -1
+	}
+}
+
+// digitArgument adds the digit of the key to the numeric argument.
+func (m *Model) digitArgument(k fmt.Stringer) {
+	s := k.String()
+	d := int(s[len(s)-1] - '0')
+	if d < 0 || d > 9 {
+		return
+	}
+	if m.yankArg.arg < 0 {
+		m.yankArg.arg = 0
+	}
+	m.yankArg.arg = m.yankArg.arg*10 + d
+}
+
+// yankLastArg inserts the last word of the previous history entry, or
+// the word designated by the numeric argument. When repeated, the
+// word inserted previously is replaced by the same word of the entry
+// before that.
+func (m *Model) yankLastArg() {
+	ya := &m.yankArg
+	start := m.history.Len() - 1
+	if ya.active {
+		start = ya.entry - 1
+	} else {
+		ya.word = ya.arg
+	}
+	ya.arg = -1
+	tokenize := m.Tokenizer
+	if tokenize == nil {
+		tokenize = computil.Words
+	}
+	for i := start; i >= 0; i-- {
+		words := tokenize(m.historyAt(i).Text)
+		w := ya.word
+		if w < 0 {
+			w = len(words) - 1
+		}
+		if w < 0 || w >= len(words) {
+			// No such word in this entry.
+			continue
+		}
+		if ya.active {
+			m.text.DeleteCharactersBackward(ya.inserted)
+		} else {
+			m.text.Checkpoint()
+		}
+		m.text.InsertString(words[w])
+		ya.active = true
+		ya.entry = i
+		ya.inserted = utf8.RuneCountInString(words[w])
+		return
+	}
+}
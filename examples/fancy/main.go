@@ -8,7 +8,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
+	"github.com/charmbracelet/lipgloss"
 	"github.com/knz/bubbline"
 	"github.com/knz/bubbline/complete"
 	"github.com/knz/bubbline/computil"
@@ -20,7 +22,8 @@ func main() {
 
 Input ends automatically on semicolon.
 Try autocompleting on 'lorem', 'all', 'hello', 'lo' followed by digits,
-or the letter 'r'.`)
+or the letter 'r'.
+Keywords like 'read' and 'quoted strings' are highlighted.`)
 	fmt.Println()
 
 	m := bubbline.New()
@@ -48,6 +51,9 @@ or the letter 'r'.`)
 		return false
 	}
 
+	// Highlight keywords and string literals.
+	m.Highlighter = highlight
+
 	// Load and configure history.
 	if err := m.LoadHistory("test.history"); err != nil {
 		fmt.Println("history load error:", err)
@@ -154,6 +160,55 @@ func autocomplete(v [][]rune, line, col int) (msg string, completions editline.C
 	return msg, completions
 }
 
+var (
+	keywordStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Bold(true)
+	stringStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+)
+
+// highlight colors the keywords and the string literals in the input.
+func highlight(v [][]rune) [][]editline.StyleSpan {
+	spans := make([][]editline.StyleSpan, len(v))
+	for i, line := range v {
+		for j := 0; j < len(line); {
+			switch {
+			case line[j] == '\'':
+				// String literal, up to the closing quote.
+				end := j + 1
+				for end < len(line) && line[end] != '\'' {
+					end++
+				}
+				if end < len(line) {
+					end++
+				}
+				spans[i] = append(spans[i], editline.StyleSpan{Start: j, End: end, Style: stringStyle})
+				j = end
+			case unicode.IsLetter(line[j]):
+				end := j + 1
+				for end < len(line) && unicode.IsLetter(line[end]) {
+					end++
+				}
+				if isKeyword(string(line[j:end])) {
+					spans[i] = append(spans[i], editline.StyleSpan{Start: j, End: end, Style: keywordStyle})
+				}
+				j = end
+			default:
+				j++
+			}
+		}
+	}
+	return spans
+}
+
+func isKeyword(w string) bool {
+	w = strings.ToUpper(w)
+	for _, kw := range keywords {
+		if kw == w {
+			return true
+		}
+	}
+	return false
+}
+
 type multiComplete struct {
 	complete.Values
 	moveRight, deleteLeft int
@@ -2,11 +2,17 @@ package bubbline
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"io"
 	"os"
 	"os/signal"
+	"sync"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/knz/bubbline/complete"
 	"github.com/knz/bubbline/editline"
 	"github.com/knz/bubbline/history"
@@ -18,25 +24,102 @@ type Editor struct {
 
 	autoSaveHistory bool
 	histFile        string
+	histFormat      history.Format
+	hfile           *history.File
+	sessionID       string
+
+	// histNamespace is the name of the history namespace in use.
+	histNamespace string
+	// namespaces is the state of the other history namespaces.
+	namespaces map[string]*historyNamespace
+
+	// input and output are the streams configured with
+	// SetInputOutput, if any.
+	input    io.Reader
+	output   io.Writer
+	renderer *lipgloss.Renderer
+
+	run runState
+}
+
+// runState is the state shared between GetLine and the goroutines
+// that interact with the editor concurrently.
+type runState struct {
+	mu sync.Mutex
+	// prog is the running program, if GetLine is in progress.
+	prog *tea.Program
+	// outBuf is the output written with Write not displayed yet.
+	outBuf []byte
+	// winSize is the window size configured with Resize, if any.
+	winSize *tea.WindowSizeMsg
+}
+
+// notify sends a message to the running program, if any. The mutex
+// must be held.
+//
+// The message should only notify the editor that the shared state
+// has changed, as messages sent concurrently can be delivered in any
+// order.
+func (r *runState) notify(msg tea.Msg) {
+	if r.prog != nil {
+		// Send blocks until the message is processed. We can't wait
+		// with the mutex held, as the editor needs the mutex to process
+		// the message. Also, this may be called from Update, e.g. by an
+		// autocompletion callback, which would deadlock.
+		go r.prog.Send(msg)
+	}
+}
+
+// setProgram registers the running program, so that further
+// notifications can be sent to it.
+func (m *Editor) setProgram(p *tea.Program) {
+	m.run.mu.Lock()
+	defer m.run.mu.Unlock()
+	m.run.prog = p
 }
 
 // New instantiates an editor.
 func New() *Editor {
 	return &Editor{
-		Model: editline.New(0, 0),
+		Model:     editline.New(0, 0),
+		sessionID: newSessionID(),
 	}
 }
 
 var _ tea.Model = (*Editor)(nil)
 
+// Init is part of the tea.Model interface.
+func (m *Editor) Init() tea.Cmd {
+	// Display the output written since the last prompt, if any.
+	cmds := []tea.Cmd{m.Model.Init(), func() tea.Msg { return asyncOutputMsg{} }}
+	if _, ok := m.windowSize(); ok {
+		cmds = append(cmds, func() tea.Msg { return resizeMsg{} })
+	}
+	return tea.Batch(cmds...)
+}
+
 // Update is part of the tea.Model interface.
 func (m *Editor) Update(imsg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := imsg.(resizeMsg); ok {
+		// Use the size configured last with Resize.
+		imsg, _ = m.windowSize()
+	}
 	switch msg := imsg.(type) {
 	case tea.WindowSizeMsg:
 		m.Model.SetSize(msg.Width, msg.Height)
 
 	case editline.InputCompleteMsg:
 		return m, tea.Quit
+
+	case asyncOutputMsg:
+		return m, m.flushOutput()
+
+	case contextDoneMsg:
+		// Stop editing, but keep the input so it can be inspected
+		// by the caller.
+		m.Model.Err = msg.err
+		m.Model.Blur()
+		return m, tea.Quit
 	}
 	_, next := m.Model.Update(imsg)
 	return m, next
@@ -53,24 +136,61 @@ var ErrInterrupted = editline.ErrInterrupted
 // by receiving SIGTERM.
 var ErrTerminated = errors.New("terminated")
 
+// ErrUnsupportedHistoryOption is returned by AppendHistory when
+// EraseDupHistory or MaxHistorySize are set while the history is kept
+// in a store other than history.MemoryStore, which cannot remove
+// entries.
+var ErrUnsupportedHistoryOption = errors.New("EraseDupHistory and MaxHistorySize require an in-memory history")
+
 // Getline runs the editor and returns the line that was read.
 func (m *Editor) GetLine() (string, error) {
-	// We don't like the default handling of SIGINT/SIGTERM. Provide our own.
+	return m.GetLineContext(context.Background())
+}
+
+// contextDoneMsg is sent to the editor when the context passed to
+// GetLineContext ends.
+type contextDoneMsg struct{ err error }
+
+// GetLineContext is like GetLine, but also stops the input when the
+// provided context is canceled or its deadline expires. In that case
+// the error returned is ctx.Err() (e.g. context.Canceled or
+// context.DeadlineExceeded) and the input entered so far is returned
+// alongside the error. It also remains available via Value() until
+// the next call to GetLine.
+func (m *Editor) GetLineContext(userCtx context.Context) (string, error) {
 	ctx, cancel := context.WithCancel(context.Background())
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, stopSignals...)
-	defer signal.Stop(ch)
+	defer cancel()
+	opts := []tea.ProgramOption{tea.WithoutSignalHandler(), tea.WithContext(ctx)}
 	var sig os.Signal
-	go func() {
-		select {
-		case sig = <-ch:
-			cancel()
-		case <-ctx.Done():
-		}
-	}()
+	if m.input != nil {
+		// Custom streams: the signals received by the process are not
+		// related to this editor.
+		opts = append(opts, tea.WithInput(m.input), tea.WithOutput(m.output))
+	} else {
+		// We don't like the default handling of SIGINT/SIGTERM. Provide our own.
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, stopSignals...)
+		defer signal.Stop(ch)
+		go func() {
+			select {
+			case sig = <-ch:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
 	// Create a Bubbletea program to handle our input.
-	p := tea.NewProgram(m, tea.WithoutSignalHandler(), tea.WithContext(ctx))
+	p := tea.NewProgram(m, opts...)
 	m.Reset()
+	m.setProgram(p)
+	defer m.setProgram(nil)
+	// Stop the program gracefully when the caller's context ends, so
+	// that the last state of the input remains displayed and the
+	// terminal is restored.
+	stop := context.AfterFunc(userCtx, func() {
+		p.Send(contextDoneMsg{err: userCtx.Err()})
+	})
+	defer stop()
 	if _, err := p.Run(); err != nil {
 		// Was a signal received?
 		if ctx.Err() != nil {
@@ -87,37 +207,197 @@ func (m *Editor) GetLine() (string, error) {
 	return m.Value(), m.Err
 }
 
+// GetPassword displays the given prompt and reads a secret, such as
+// a password, with the EchoPassword mode (see the EchoMode field of
+// editline.Model). The settings of the editor are restored
+// afterwards, and the input is overwritten in memory.
+func (m *Editor) GetPassword(prompt string) (string, error) {
+	defer func(prompt, nextPrompt string, promptFunc func(int, editline.EditMode) string, mode editline.EchoMode) {
+		m.Prompt, m.NextPrompt, m.PromptFunc, m.EchoMode = prompt, nextPrompt, promptFunc, mode
+		m.Reset()
+	}(m.Prompt, m.NextPrompt, m.PromptFunc, m.EchoMode)
+	m.Prompt, m.NextPrompt, m.PromptFunc = prompt, "", nil
+	m.EchoMode = editline.EchoPassword
+	return m.GetLine()
+}
+
 // AddHistory adds a history entry and optionally saves
 // the history to file.
 func (m *Editor) AddHistory(line string) error {
-	m.AddHistoryEntry(line)
-	if m.autoSaveHistory && m.histFile != "" {
+	return m.AppendHistory(history.Entry{Text: line})
+}
+
+// AppendHistory adds a history entry with its metadata, and
+// optionally saves the history to file. The time, session ID and
+// working directory are filled in if not set already. The privacy
+// settings of the editor (HistoryIgnoreSpace, HistoryIgnorePatterns
+// and HistoryRedact) are applied first: the ignored entries are only
+// kept in the history of the current session.
+//
+// When saving to file, the entry is appended to the file, and the
+// entries appended to the file by other sessions in the meantime are
+// merged into the history first. This makes it possible to share a
+// history file between concurrent sessions. If MaxHistorySize is set,
+// the file is compacted when it grows larger than twice that size.
+// With EraseDupHistory, the file is also compacted when the entry
+// replaces an older duplicate.
+//
+// If a store was configured with SetHistoryStore, the entry is
+// appended to the store instead, and the errors of the store are
+// returned. EraseDupHistory and MaxHistorySize are not supported in
+// that case: ErrUnsupportedHistoryOption is returned and the entry is
+// not added.
+func (m *Editor) AppendHistory(e history.Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.SessionID == "" {
+		e.SessionID = m.sessionID
+	}
+	if e.Dir == "" {
+		e.Dir, _ = os.Getwd()
+	}
+	e = m.FilterHistoryEntry(e)
+	if s := m.HistoryStore(); !isMemoryStore(s) {
+		if m.EraseDupHistory || m.MaxHistorySize != 0 {
+			return ErrUnsupportedHistoryOption
+		}
+		// The history is saved by the store.
+		if n := s.Len(); m.DedupHistory && n > 0 {
+			if last, err := s.At(n - 1); err == nil && history.SameText(last.Text, e.Text, m.DedupWhitespace) {
+				// Duplicate entry; nothing to save.
+				return nil
+			}
+		}
+		err := s.Append(e)
+		m.SetHistoryStore(s)
+		return err
+	}
+	if !m.autoSaveHistory || m.histFile == "" || e.Ephemeral {
+		m.AppendHistoryEntry(e)
+		return nil
+	}
+	if h := m.GetHistoryEntries(); m.DedupHistory && !m.EraseDupHistory && len(h) > 0 &&
+		history.SameText(h[len(h)-1].Text, e.Text, m.DedupWhitespace) {
+		// Duplicate entry; nothing to save.
+		return nil
+	}
+	f := m.historyFile()
+	others, reload, err := f.Append(e)
+	if reload {
+		// Keep the entries of the current session that are not in the
+		// file.
+		for _, o := range m.GetHistoryEntries() {
+			if o.Ephemeral {
+				others = append(others, o)
+			}
+		}
+		m.SetHistoryEntries(others)
+	} else {
+		for _, o := range others {
+			m.AppendHistoryEntry(o)
+		}
+	}
+	dup := m.EraseDupHistory && m.hasHistoryDuplicate(e)
+	m.AppendHistoryEntry(e)
+	if err != nil {
+		return err
+	}
+	if dup {
+		// Remove the older duplicates from the file.
+		return m.SaveHistory()
+	}
+	if m.MaxHistorySize > 0 && f.Len() > 2*m.MaxHistorySize {
+		// Prevent the file from growing indefinitely.
 		return m.SaveHistory()
 	}
 	return nil
 }
 
-// LoadHistory loads the entry history from file.
+// isMemoryStore returns true if the history is kept in memory.
+func isMemoryStore(s history.Store) bool {
+	_, ok := s.(*history.MemoryStore)
+	return ok
+}
+
+// hasHistoryDuplicate returns true if the history contains a
+// persistent entry equal to e.
+func (m *Editor) hasHistoryDuplicate(e history.Entry) bool {
+	for _, o := range m.GetHistoryEntries() {
+		if !o.Ephemeral && history.SameText(o.Text, e.Text, m.DedupWhitespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadHistory loads the entry history from file. Both the libedit
+// format and the JSON lines format are recognized.
 func (m *Editor) LoadHistory(file string) error {
-	h, err := history.LoadHistory(file)
+	f := history.NewFile(file, m.histFormat)
+	h, err := f.Load()
 	if err != nil {
 		return err
 	}
-	m.SetHistory(h)
+	m.hfile = f
+	m.SetHistoryEntries(h)
 	return nil
 }
 
 // SaveHistory saves the current history to the file
 // previously configured with SetAutoSaveHistory.
+// The previous contents of the file are replaced atomically,
+// preserving the entries appended by other sessions in the meantime.
+// These are also merged into the current history.
 func (m *Editor) SaveHistory() error {
 	if m.histFile == "" {
 		return errors.New("no savefile configured")
 	}
-	h := m.GetHistory()
+	h := m.GetHistoryEntries()
 	if h == nil {
 		return errors.New("history not configured")
 	}
-	return history.SaveHistory(h, m.histFile)
+	h, err := m.historyFile().Compact(h)
+	if err != nil {
+		return err
+	}
+	m.SetHistoryEntries(h)
+	return nil
+}
+
+// historyFile returns the history file configured with
+// SetAutoSaveHistory.
+func (m *Editor) historyFile() *history.File {
+	if m.hfile == nil || m.hfile.Name() != m.histFile {
+		m.hfile = history.NewFile(m.histFile, m.histFormat)
+	}
+	return m.hfile
+}
+
+// SetHistoryFormat configures the format used to save the history
+// to file. The default is history.FormatLibedit, which does not
+// preserve the metadata of the entries. An existing file keeps its
+// format until it is rewritten by SaveHistory, except that a file in
+// the JSON lines format is never converted back to the libedit format.
+func (m *Editor) SetHistoryFormat(format history.Format) {
+	m.histFormat = format
+	if m.hfile != nil {
+		m.hfile.SetFormat(format)
+	}
+}
+
+// SetHistorySessionID configures the session ID recorded in the
+// history entries added with AddHistory. By default, a random ID is
+// generated for each editor.
+func (m *Editor) SetHistorySessionID(id string) {
+	m.sessionID = id
+}
+
+// newSessionID generates a random session ID.
+func newSessionID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
 }
 
 // SetAutoSaveHistory enables/disables auto-saving of entered lines
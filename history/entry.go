@@ -0,0 +1,277 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Entry is a history entry with its metadata.
+type Entry struct {
+	// Text is the input that was entered.
+	Text string `json:"text"`
+	// Time is when the input was entered.
+	Time time.Time `json:"time,omitzero"`
+	// SessionID identifies the session in which the input was
+	// entered, e.g. to tell apart the inputs of concurrent sessions.
+	SessionID string `json:"session,omitempty"`
+	// Dir is the working directory at the time the input was entered.
+	Dir string `json:"dir,omitempty"`
+	// Status is an optional application-defined status for the
+	// input, for example "ok" or "error".
+	Status string `json:"status,omitempty"`
+	// Duration is the optional duration of the processing of the
+	// input.
+	Duration time.Duration `json:"duration,omitempty"`
+	// Ephemeral, if true, keeps the entry in the history of the
+	// current session only: it is never written to a history file.
+	Ephemeral bool `json:"-"`
+}
+
+// Format is the on-disk format of a history file.
+type Format int
+
+const (
+	// FormatLibedit is the format used by libedit. Only the text of
+	// the entries is stored.
+	FormatLibedit Format = iota
+	// FormatJSONL stores the entries with their metadata as JSON
+	// objects, one per line.
+	FormatJSONL
+)
+
+// String implements the fmt.Stringer interface.
+func (f Format) String() string {
+	switch f {
+	case FormatLibedit:
+		return "libedit"
+	case FormatJSONL:
+		return "jsonl"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// Texts returns the text of the given entries.
+func Texts(h []Entry) []string {
+	if h == nil {
+		return nil
+	}
+	res := make([]string, len(h))
+	for i, e := range h {
+		res[i] = e.Text
+	}
+	return res
+}
+
+// FromTexts returns entries with the given text and no metadata.
+func FromTexts(h []string) []Entry {
+	if h == nil {
+		return nil
+	}
+	res := make([]Entry, len(h))
+	for i, s := range h {
+		res[i].Text = s
+	}
+	return res
+}
+
+// Equal returns true if the two entries have the same text and
+// metadata.
+func (e Entry) Equal(o Entry) bool {
+	return e.Text == o.Text && e.Time.Equal(o.Time) && e.SessionID == o.SessionID &&
+		e.Dir == o.Dir && e.Status == o.Status && e.Duration == o.Duration
+}
+
+// SameText returns true if the two texts are equal. If ignoreSpace
+// is true, differences in whitespace are ignored: leading and trailing
+// whitespace, and the length and type of whitespace sequences.
+func SameText(a, b string, ignoreSpace bool) bool {
+	if ignoreSpace {
+		return normalizeSpace(a) == normalizeSpace(b)
+	}
+	return a == b
+}
+
+// normalizeSpace removes the leading and trailing whitespace, and
+// replaces the other whitespace sequences by a single space.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Dedup returns the entries of h without those that have the same
+// text as a more recent entry, in the manner of HISTCONTROL=erasedups
+// in bash. The texts are compared with SameText. Ephemeral entries do
+// not replace the older entries, so that the persistent entries are
+// not lost when the history is saved.
+func Dedup(h []Entry, ignoreSpace bool) []Entry {
+	seen := make(map[string]struct{}, len(h))
+	res := make([]Entry, len(h))
+	n := len(h)
+	for i := len(h) - 1; i >= 0; i-- {
+		key := h[i].Text
+		if ignoreSpace {
+			key = normalizeSpace(key)
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		if !h[i].Ephemeral {
+			seen[key] = struct{}{}
+		}
+		n--
+		res[n] = h[i]
+	}
+	return res[n:]
+}
+
+// persistent returns the entries that are not ephemeral.
+func persistent(h []Entry) []Entry {
+	for i, e := range h {
+		if e.Ephemeral {
+			// Copy, so as not to modify the caller's slice.
+			res := append([]Entry(nil), h[:i]...)
+			for _, e := range h[i+1:] {
+				if !e.Ephemeral {
+					res = append(res, e)
+				}
+			}
+			return res
+		}
+	}
+	return h
+}
+
+// entryKey identifies an entry by its text and time, to find the
+// entries already present in a history.
+type entryKey struct {
+	text string
+	time int64
+}
+
+func keyOf(e Entry) entryKey {
+	return entryKey{text: e.Text, time: e.Time.UnixNano()}
+}
+
+// LoadEntries loads a history from the file at the specified path.
+// Both the libedit format and the JSON lines format are recognized.
+// The entries loaded from a file in the libedit format have no
+// metadata.
+func LoadEntries(fileName string) ([]Entry, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return loadEntriesFromFile(f)
+}
+
+func loadEntriesFromFile(f io.Reader) ([]Entry, error) {
+	h, _, err := loadEntriesAndFormat(f)
+	return h, err
+}
+
+// loadEntriesAndFormat loads the entries from a history file, and
+// also returns the format detected.
+func loadEntriesAndFormat(f io.Reader) ([]Entry, Format, error) {
+	r := bufio.NewReader(f)
+	start, err := r.Peek(1)
+	if err == io.EOF {
+		// empty file.
+		return nil, FormatLibedit, nil
+	}
+	if err != nil {
+		return nil, FormatLibedit, err
+	}
+	if start[0] != '{' {
+		h, err := loadHistoryFromFile(r)
+		return FromTexts(h), FormatLibedit, err
+	}
+	h, err := decodeEntries(r, FormatJSONL)
+	return h, FormatJSONL, err
+}
+
+// decodeEntries decodes history entries in the given format. For the
+// libedit format, the cookie must have been consumed already.
+func decodeEntries(r io.Reader, format Format) ([]Entry, error) {
+	switch format {
+	case FormatLibedit:
+		h, err := decodeLines(r)
+		return FromTexts(h), err
+	case FormatJSONL:
+		var hist []Entry
+		dec := json.NewDecoder(r)
+		for {
+			var e Entry
+			if err := dec.Decode(&e); err != nil {
+				if err == io.EOF {
+					return hist, nil
+				}
+				return nil, fmt.Errorf("history entry %d: %w", len(hist)+1, err)
+			}
+			hist = append(hist, e)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported history format: %v", format)
+	}
+}
+
+// SaveEntries saves a history to the specified file, in the
+// specified format. The previous contents of the file are replaced
+// atomically. Ephemeral entries are not saved.
+func SaveEntries(h []Entry, fileName string, format Format) error {
+	unlock, err := lock(fileName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeFileAtomic(fileName, func(w io.Writer) error {
+		return saveEntriesToFile(h, w, format)
+	})
+}
+
+func saveEntriesToFile(h []Entry, f io.Writer, format Format) error {
+	h = persistent(h)
+	if format == FormatLibedit {
+		return saveHistoryToFile(Texts(h), f)
+	}
+	w := bufio.NewWriter(f)
+	if err := encodeEntries(w, h, format); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// encodeEntries encodes history entries in the given format. For the
+// libedit format, the cookie is not included. Ephemeral entries are
+// skipped.
+func encodeEntries(w io.Writer, h []Entry, format Format) error {
+	h = persistent(h)
+	switch format {
+	case FormatLibedit:
+		return encodeLines(w, Texts(h))
+	case FormatJSONL:
+		for _, e := range h {
+			j, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			// The encoding escapes newline characters, so each entry
+			// remains on its own line.
+			j = append(j, '\n')
+			if _, err := w.Write(j); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported history format: %v", format)
+	}
+}
@@ -0,0 +1,126 @@
+package history
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadEntries(t *testing.T) {
+	ts := time.Date(2022, 8, 1, 10, 30, 0, 0, time.UTC)
+	testCases := []struct {
+		input  string
+		exp    []Entry
+		expErr string
+	}{
+		{"", nil, ""},
+		{"foo", nil, ""},
+		{"_HiStOrY_V2_\nfoo\\040bar\nbaz", []Entry{{Text: "foo bar"}, {Text: "baz"}}, ""},
+		{`{"text":"foo"}`, []Entry{{Text: "foo"}}, ""},
+		{"{\"text\":\"foo\\nbar\",\"time\":\"2022-08-01T10:30:00Z\"}\n{\"text\":\"baz\",\"status\":\"ok\"}\n",
+			[]Entry{{Text: "foo\nbar", Time: ts}, {Text: "baz", Status: "ok"}}, ""},
+		{"{\"text\":\"foo\"}\n{\"text\":", nil, "history entry 2: unexpected EOF"},
+		{"{\"text\":\"foo\"}\n{\"text\":123}", nil,
+			"history entry 2: json: cannot unmarshal number into Go struct field Entry.text of type string"},
+	}
+
+	for _, tc := range testCases {
+		buf := bytes.NewBufferString(tc.input)
+		h, err := loadEntriesFromFile(buf)
+		if tc.expErr != "" {
+			if err == nil {
+				t.Errorf("%q: expected error, got no error", tc.input)
+			} else if err.Error() != tc.expErr {
+				t.Errorf("%q: expected error:\n%s, got:\n%v", tc.input, tc.expErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: expected no error, got: %v", tc.input, err)
+		}
+		if !reflect.DeepEqual(tc.exp, h) {
+			t.Errorf("%q: expected:\n%+v\ngot:\n%+v", tc.input, tc.exp, h)
+		}
+	}
+}
+
+func TestSaveEntries(t *testing.T) {
+	ts := time.Date(2022, 8, 1, 10, 30, 0, 0, time.UTC)
+	entries := []Entry{
+		{Text: "foo bar", Time: ts, SessionID: "s1", Dir: "/tmp"},
+		{Text: "multi\nline", Status: "error", Duration: time.Second},
+	}
+	testCases := []struct {
+		input  []Entry
+		format Format
+		exp    string
+		expErr string
+	}{
+		{nil, FormatLibedit, "_HiStOrY_V2_\n", ""},
+		{nil, FormatJSONL, "", ""},
+		{entries, FormatLibedit, "_HiStOrY_V2_\nfoo\\040bar\nmulti\\012line\n", ""},
+		{entries, FormatJSONL,
+			`{"text":"foo bar","time":"2022-08-01T10:30:00Z","session":"s1","dir":"/tmp"}` + "\n" +
+				`{"text":"multi\nline","status":"error","duration":1000000000}` + "\n", ""},
+		{entries, Format(42), "", "unsupported history format: Format(42)"},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		err := saveEntriesToFile(tc.input, &buf, tc.format)
+		if tc.expErr != "" {
+			if err == nil {
+				t.Errorf("%v: expected error, got no error", tc.format)
+			} else if err.Error() != tc.expErr {
+				t.Errorf("%v: expected error:\n%s, got:\n%v", tc.format, tc.expErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: expected no error, got: %v", tc.format, err)
+		}
+		if result := buf.String(); result != tc.exp {
+			t.Errorf("%v: expected:\n%q\ngot:\n%q", tc.format, tc.exp, result)
+		}
+
+		// The entries can be loaded back.
+		h, err := loadEntriesFromFile(&buf)
+		if err != nil {
+			t.Errorf("%v: expected no error, got: %v", tc.format, err)
+		}
+		exp := tc.input
+		if tc.format == FormatLibedit {
+			exp = FromTexts(Texts(exp))
+		}
+		if !reflect.DeepEqual(exp, h) {
+			t.Errorf("%v: expected:\n%+v\ngot:\n%+v", tc.format, exp, h)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	testCases := []struct {
+		input       []string
+		ignoreSpace bool
+		exp         []string
+	}{
+		{nil, false, []string{}},
+		{[]string{"a", "b", "c"}, false, []string{"a", "b", "c"}},
+		{[]string{"a", "b", "a", "c", "b"}, false, []string{"a", "c", "b"}},
+		{[]string{"a  b", "c", " a b\n"}, false, []string{"a  b", "c", " a b\n"}},
+		{[]string{"a  b", "c", " a b\n"}, true, []string{"c", " a b\n"}},
+	}
+	for _, tc := range testCases {
+		h := Texts(Dedup(FromTexts(tc.input), tc.ignoreSpace))
+		if !reflect.DeepEqual(h, tc.exp) {
+			t.Errorf("%q (ignoreSpace: %v): expected %q, got %q", tc.input, tc.ignoreSpace, tc.exp, h)
+		}
+	}
+
+	// Ephemeral entries do not replace the older entries.
+	h := []Entry{{Text: "a"}, {Text: "b"}, {Text: " a", Ephemeral: true}, {Text: "b", Ephemeral: true}}
+	if exp, res := []string{"a", "b", " a", "b"}, Texts(Dedup(h, true)); !reflect.DeepEqual(res, exp) {
+		t.Errorf("expected %q, got %q", exp, res)
+	}
+}
@@ -0,0 +1,310 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrEventNotFound is returned by Expand when a history reference
+// does not designate any entry.
+var ErrEventNotFound = errors.New("event not found")
+
+// ErrBadWordSpecifier is returned by Expand when a word designator
+// does not designate any word of the entry.
+var ErrBadWordSpecifier = errors.New("bad word specifier")
+
+// ErrSubstitutionFailed is returned by Expand when the text to
+// replace in a quick substitution is not found.
+var ErrSubstitutionFailed = errors.New("substitution failed")
+
+// Expand performs history expansion on the input, in the manner of
+// bash, given the history entries h, most recent last. It reports
+// whether any expansion took place.
+//
+// The following references are recognized:
+//
+//	!!          the previous entry
+//	!n          the n-th entry, counting from 1
+//	!-n         the n-th previous entry
+//	!string     the most recent entry starting with string, where
+//	            string ends at whitespace, ':' or one of ";&()|<>"
+//	!?string?   the most recent entry containing string
+//	!$          the last word of the previous entry
+//	!^          the first argument (second word) of the previous entry
+//	!*          all the arguments of the previous entry
+//	^old^new^   the previous entry with old replaced by new, at the
+//	            start of the input only
+//
+// The references to an entry can be followed by a word designator:
+// ":n" for the n-th word, counting from 0, or ":^", ":$" or ":*" as
+// above. Words are separated by whitespace, except within quotes.
+//
+// The exclamation mark is not special when followed by whitespace,
+// '=' or '(', when escaped with a backslash, or within single quotes.
+func Expand(input string, h []string) (result string, expanded bool, err error) {
+	return expand(input, textList(h))
+}
+
+// ExpandStore is like Expand, but reads the history entries from a
+// Store. Only the entries designated by the references are read, so
+// that a large history does not need to be loaded in memory. The
+// errors of the store are returned.
+func ExpandStore(input string, s Store) (result string, expanded bool, err error) {
+	return expand(input, s)
+}
+
+// entryList provides the history entries to Expand, oldest first.
+type entryList interface {
+	Len() int
+	At(i int) (Entry, error)
+}
+
+// textList is an entryList of texts.
+type textList []string
+
+func (l textList) Len() int                { return len(l) }
+func (l textList) At(i int) (Entry, error) { return Entry{Text: l[i]}, nil }
+
+func expand(input string, h entryList) (result string, expanded bool, err error) {
+	if strings.HasPrefix(input, "^") {
+		return quickSubstitution(input, h)
+	}
+	var buf strings.Builder
+	inSingle, inDouble := false, false
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case c == '\\' && !inSingle && i+1 < len(input):
+			// Escaped character: keep as-is.
+			buf.WriteString(input[i : i+2])
+			i++
+			continue
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '!' && !inSingle:
+			n, repl, err := expandReference(input[i:], h, inDouble)
+			if err != nil {
+				return input, false, err
+			}
+			if n > 0 {
+				buf.WriteString(repl)
+				expanded = true
+				i += n - 1
+				continue
+			}
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String(), expanded, nil
+}
+
+// expandReference expands the history reference at the start of s,
+// which starts with an exclamation mark. It returns the length of the
+// reference, or zero if the exclamation mark is not special.
+func expandReference(s string, h entryList, inDouble bool) (n int, repl string, err error) {
+	if len(s) < 2 || strings.IndexByte(" \t\n=(", s[1]) >= 0 || (inDouble && s[1] == '"') {
+		return 0, "", nil
+	}
+	var entry string
+	var found bool
+	switch c := s[1]; {
+	case c == '!':
+		n = 2
+		entry, found, err = relativeEntry(h, 1)
+	case c == '$' || c == '^' || c == '*' || c == ':':
+		// Word designator for the previous entry. It is parsed below.
+		n = 1
+		entry, found, err = relativeEntry(h, 1)
+	case c == '-' || (c >= '0' && c <= '9'):
+		n = 2
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		if s[1:n] == "-" {
+			// "!-" without a number.
+			return 0, "", nil
+		}
+		num, convErr := strconv.Atoi(s[1:n])
+		switch {
+		case convErr != nil:
+			// The number is out of range: there is no such entry.
+		case num < 0:
+			entry, found, err = relativeEntry(h, -num)
+		case num > 0:
+			entry, found, err = nthEntry(h, num-1)
+		}
+	case c == '?':
+		end := strings.IndexAny(s[2:], "?\n")
+		n = len(s)
+		if end >= 0 {
+			n = 2 + end
+			if s[n] == '?' {
+				n++
+			}
+			end += 2
+		} else {
+			end = n
+		}
+		entry, found, err = searchEntry(h, s[2:end], strings.Contains)
+	default:
+		// The string ends like a word, as in bash.
+		n = 1
+		for n < len(s) && strings.IndexByte(" \t\n:;&()|<>", s[n]) < 0 && !(inDouble && s[n] == '"') {
+			n++
+		}
+		entry, found, err = searchEntry(h, s[1:n], strings.HasPrefix)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if !found {
+		return 0, "", fmt.Errorf("%s: %w", s[:max(n, 2)], ErrEventNotFound)
+	}
+
+	// Word designator, if any.
+	shorthand := n == 1
+	if n+1 < len(s) && s[n] == ':' && strings.IndexByte("0123456789^$*", s[n+1]) >= 0 {
+		n++
+	} else if !shorthand {
+		// No word designator: the entire entry.
+		return n, entry, nil
+	} else if s[n] == ':' {
+		return 0, "", fmt.Errorf("%s: %w", s[:n+1], ErrBadWordSpecifier)
+	}
+	words := splitWords(entry)
+	switch c := s[n]; {
+	case c == '^':
+		n++
+		if len(words) < 2 {
+			return 0, "", fmt.Errorf("%s: %w", s[:n], ErrBadWordSpecifier)
+		}
+		return n, words[1], nil
+	case c == '$':
+		n++
+		if len(words) == 0 {
+			return 0, "", fmt.Errorf("%s: %w", s[:n], ErrBadWordSpecifier)
+		}
+		return n, words[len(words)-1], nil
+	case c == '*':
+		n++
+		if len(words) < 2 {
+			return n, "", nil
+		}
+		return n, strings.Join(words[1:], " "), nil
+	default:
+		numStart := n
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		num, _ := strconv.Atoi(s[numStart:n])
+		if num >= len(words) {
+			return 0, "", fmt.Errorf("%s: %w", s[:n], ErrBadWordSpecifier)
+		}
+		return n, words[num], nil
+	}
+}
+
+// nthEntry returns the entry at position i, counting from 0.
+func nthEntry(h entryList, i int) (string, bool, error) {
+	if i < 0 || i >= h.Len() {
+		return "", false, nil
+	}
+	e, err := h.At(i)
+	return e.Text, err == nil, err
+}
+
+// relativeEntry returns the n-th previous entry.
+func relativeEntry(h entryList, n int) (string, bool, error) {
+	if n <= 0 {
+		return "", false, nil
+	}
+	return nthEntry(h, h.Len()-n)
+}
+
+// searchEntry returns the most recent entry that matches s.
+func searchEntry(h entryList, s string, match func(entry, s string) bool) (string, bool, error) {
+	if s == "" {
+		return "", false, nil
+	}
+	for i := h.Len() - 1; i >= 0; i-- {
+		e, err := h.At(i)
+		if err != nil {
+			return "", false, err
+		}
+		if match(e.Text, s) {
+			return e.Text, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// quickSubstitution expands "^old^new^rest" into the previous entry
+// with the first occurrence of old replaced by new, followed by rest.
+func quickSubstitution(input string, h entryList) (string, bool, error) {
+	parts := strings.SplitN(input[1:], "^", 3)
+	spec := input
+	if len(parts) == 3 {
+		spec = input[:len(input)-len(parts[2])]
+	}
+	entry, found, err := relativeEntry(h, 1)
+	if err != nil {
+		return input, false, err
+	}
+	if !found {
+		return input, false, fmt.Errorf("%s: %w", spec, ErrEventNotFound)
+	}
+	old, repl, rest := parts[0], "", ""
+	if len(parts) > 1 {
+		repl = parts[1]
+	}
+	if len(parts) > 2 {
+		rest = parts[2]
+	}
+	if old == "" || !strings.Contains(entry, old) {
+		return input, false, fmt.Errorf("%s: %w", spec, ErrSubstitutionFailed)
+	}
+	return strings.Replace(entry, old, repl, 1) + rest, true, nil
+}
+
+// splitWords splits an entry into words separated by whitespace. The
+// quoted parts of the entry are not split.
+func splitWords(s string) []string {
+	var words []string
+	start := -1
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\n' {
+			if start >= 0 {
+				words = append(words, s[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '\\':
+			i++
+		}
+	}
+	if start >= 0 {
+		words = append(words, s[start:])
+	}
+	return words
+}
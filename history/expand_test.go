@@ -0,0 +1,137 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	h := []string{
+		"select * from users",
+		"insert into t values (1, 'a b')",
+		`echo "hello world" foo`,
+		"select 42",
+	}
+	testCases := []struct {
+		input  string
+		exp    string
+		expErr string
+	}{
+		// No expansion.
+		{"select 1", "", ""},
+		{"select 1 != 2", "", ""},
+		{"select '!!'", "", ""},
+		{`select \!!`, "", ""},
+		{"select !", "", ""},
+		{"select !(x)", "", ""},
+		{"!-x", "", ""},
+
+		// Event designators.
+		{"!!", "select 42", ""},
+		{"!! + 1", "select 42 + 1", ""},
+		{"!1", "select * from users", ""},
+		{"!-2", `echo "hello world" foo`, ""},
+		{"!ins", "insert into t values (1, 'a b')", ""},
+		{"!sel;", "select 42;", ""},
+		{"!?users?;", "select * from users;", ""},
+		{"!?users", "select * from users", ""},
+		{`select "!!"`, `select "select 42"`, ""},
+		{`select "!sel"`, `select "select 42"`, ""},
+
+		// Word designators.
+		{"!$", "42", ""},
+		{"!^", "42", ""},
+		{"!*", "42", ""},
+		{"ls !-2:$", "ls foo", ""},
+		{"ls !-2:*", `ls "hello world" foo`, ""},
+		{"!-2:1", `"hello world"`, ""},
+		{"!ins:$", "'a b')", ""},
+		{"!:0", "select", ""},
+		{"!!:0 1", "select 1", ""},
+
+		// Quick substitution.
+		{"^42^43", "select 43", ""},
+		{"^42^43^ + 1", "select 43 + 1", ""},
+
+		// Errors.
+		{"!foo", "", "!foo: event not found"},
+		{"!9", "", "!9: event not found"},
+		{"!-9", "", "!-9: event not found"},
+		{"!99999999999999999999", "", "!99999999999999999999: event not found"},
+		{"!-99999999999999999999", "", "!-99999999999999999999: event not found"},
+		{"!?nope?", "", "!?nope?: event not found"},
+		{"!!:5", "", "!!:5: bad word specifier"},
+		{"!:x", "", "!:: bad word specifier"},
+		{"^nope^x", "", "^nope^x: substitution failed"},
+	}
+
+	s := NewMemoryStore(FromTexts(h))
+	for _, tc := range testCases {
+		res, expanded, err := Expand(tc.input, h)
+		// The result is the same with a store.
+		if sRes, sExpanded, sErr := ExpandStore(tc.input, s); sRes != res || sExpanded != expanded ||
+			fmt.Sprint(sErr) != fmt.Sprint(err) {
+			t.Errorf("%q: expected %q (%v, %v) from the store, got %q (%v, %v)",
+				tc.input, res, expanded, err, sRes, sExpanded, sErr)
+		}
+		if tc.expErr != "" {
+			if err == nil {
+				t.Errorf("%q: expected error, got %q", tc.input, res)
+			} else if err.Error() != tc.expErr {
+				t.Errorf("%q: expected error:\n%s, got:\n%v", tc.input, tc.expErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: expected no error, got: %v", tc.input, err)
+			continue
+		}
+		if tc.exp == "" {
+			if expanded || res != tc.input {
+				t.Errorf("%q: expected no expansion, got %q", tc.input, res)
+			}
+			continue
+		}
+		if !expanded || res != tc.exp {
+			t.Errorf("%q: expected %q, got %q (expanded: %v)", tc.input, tc.exp, res, expanded)
+		}
+	}
+
+	if _, _, err := Expand("!!", nil); err == nil || err.Error() != "!!: event not found" {
+		t.Errorf("expected event not found with empty history, got %v", err)
+	}
+
+	// The errors of the store are returned.
+	fs, err := OpenFileStore(filepath.Join(t.TempDir(), "history"), FormatJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Append(FromTexts(h)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ExpandStore("!!", fs); err == nil {
+		t.Error("expected error from the closed store")
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	testCases := []struct {
+		input string
+		exp   []string
+	}{
+		{"", nil},
+		{"  a  b\tc\n", []string{"a", "b", "c"}},
+		{`a "b c" 'd e'f g\ h`, []string{"a", `"b c"`, `'d e'f`, `g\ h`}},
+		{`"a \" b" c`, []string{`"a \" b"`, "c"}},
+	}
+	for _, tc := range testCases {
+		if res := splitWords(tc.input); !reflect.DeepEqual(res, tc.exp) {
+			t.Errorf("%q: expected %q, got %q", tc.input, tc.exp, res)
+		}
+	}
+}
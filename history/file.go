@@ -0,0 +1,280 @@
+package history
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// File is a history file shared by concurrent sessions, for example
+// multiple shells run by the same user.
+//
+// New entries are appended to the file incrementally, instead of
+// rewriting the entire file. The entries appended by other sessions
+// since the last access are returned upon each access, so that they
+// can be merged into the history of the current session. The file is
+// only rewritten upon compaction, via a temporary file renamed into
+// place, so that the history is not lost if the process is
+// interrupted during the write.
+//
+// All accesses are serialized with an advisory lock on a separate lock
+// file, named after the history file with the ".lock" suffix. The lock
+// is a no-op on the platforms without file locking, e.g. js/wasm.
+type File struct {
+	name string
+	// format is the format used to create or rewrite the file.
+	format Format
+	// fileFormat is the format of the existing file, as of the last
+	// access.
+	fileFormat Format
+
+	// info identifies the file as of the last access. It is used to
+	// detect when the file is replaced by another session.
+	info os.FileInfo
+	// offset is the size of the file as of the last access.
+	offset int64
+	// count is the number of entries in the file as of the last access.
+	count int
+}
+
+// NewFile prepares access to the history file at the specified path.
+// The file is created upon the first write if it does not exist yet,
+// using the given format. An existing file keeps its format.
+func NewFile(fileName string, format Format) *File {
+	return &File{name: fileName, format: format}
+}
+
+// Name returns the path to the file.
+func (f *File) Name() string { return f.name }
+
+// Len returns the number of entries in the file as of the last access.
+func (f *File) Len() int { return f.count }
+
+// SetFormat configures the format used to create the file, or to
+// rewrite it upon compaction. A file in the JSON lines format keeps
+// its format upon compaction, so that the metadata of the entries is
+// not lost.
+func (f *File) SetFormat(format Format) { f.format = format }
+
+// Load loads all the entries in the file.
+func (f *File) Load() (h []Entry, err error) {
+	err = f.withLock(func() error {
+		f.info, f.offset, f.count = nil, 0, 0
+		h, _, err = f.sync()
+		return err
+	})
+	return h, err
+}
+
+// Append appends the given entries to the file. Ephemeral entries
+// are skipped.
+//
+// It returns the entries appended by other sessions since the last
+// access, which precede the new entries in the file. If the file was
+// replaced since the last access, for example after compaction by
+// another session, reload is true and the entries returned are the
+// entire contents of the file prior to the new entries: they should
+// then replace the history of the current session.
+func (f *File) Append(entries ...Entry) (others []Entry, reload bool, err error) {
+	err = f.withLock(func() error {
+		others, reload, err = f.sync()
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if f.offset == 0 {
+			// New file. Use the configured format.
+			f.fileFormat = f.format
+			if f.fileFormat == FormatLibedit {
+				buf.WriteString(cookie + "\n")
+			}
+		}
+		if err := encodeEntries(&buf, entries, f.fileFormat); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(f.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(buf.Bytes())
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		f.count += len(persistent(entries))
+		return f.updateInfo()
+	})
+	return others, reload, err
+}
+
+// Compact replaces the contents of the file by the given entries,
+// followed by the entries appended by other sessions since the last
+// access. If the file was replaced since the last access, the entries
+// of the new file not in h are kept instead. Compact returns the new
+// contents of the file, along with the ephemeral entries of h which
+// are not written, which should then replace the history of the
+// current session.
+func (f *File) Compact(h []Entry) (newEntries []Entry, err error) {
+	err = f.withLock(func() error {
+		others, reload, err := f.sync()
+		if err != nil {
+			return err
+		}
+		newEntries = append([]Entry(nil), h...)
+		var known map[entryKey]struct{}
+		if reload {
+			known = make(map[entryKey]struct{}, len(h))
+			for _, e := range h {
+				known[keyOf(e)] = struct{}{}
+			}
+		}
+		for _, o := range others {
+			if _, ok := known[keyOf(o)]; !ok {
+				newEntries = append(newEntries, o)
+			}
+		}
+		format := f.format
+		if f.info != nil && f.fileFormat == FormatJSONL {
+			// Keep the metadata of the existing file.
+			format = FormatJSONL
+		}
+		if err := writeFileAtomic(f.name, func(w io.Writer) error {
+			return saveEntriesToFile(newEntries, w, format)
+		}); err != nil {
+			return err
+		}
+		f.fileFormat, f.count = format, len(persistent(newEntries))
+		return f.updateInfo()
+	})
+	return newEntries, err
+}
+
+// sync reads the entries appended to the file since the last access,
+// or the entire file if it was replaced in the meantime. The lock
+// must be held.
+func (f *File) sync() (entries []Entry, reload bool, err error) {
+	info, err := os.Stat(f.name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// The file does not exist (any more).
+			reload = f.info != nil
+			f.info, f.offset, f.count = nil, 0, 0
+			return nil, reload, nil
+		}
+		return nil, false, err
+	}
+	if f.info != nil && (!os.SameFile(f.info, info) || info.Size() < f.offset) {
+		// The file was replaced or truncated. Read it again from the
+		// start.
+		reload = true
+		f.offset, f.count = 0, 0
+	}
+	if info.Size() == f.offset {
+		// Nothing new.
+		f.info = info
+		return nil, reload, nil
+	}
+	file, err := os.Open(f.name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = file.Close() }()
+	if f.offset == 0 {
+		entries, f.fileFormat, err = loadEntriesAndFormat(file)
+	} else {
+		if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+			return nil, false, err
+		}
+		entries, err = decodeEntries(file, f.fileFormat)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	f.count += len(entries)
+	return entries, reload, f.updateInfo()
+}
+
+// updateInfo records the identity and the size of the file after an
+// access. The lock must be held.
+func (f *File) updateInfo() error {
+	info, err := os.Stat(f.name)
+	if err != nil {
+		return err
+	}
+	f.info, f.offset = info, info.Size()
+	return nil
+}
+
+// withLock runs fn with the lock held.
+func (f *File) withLock(fn func() error) error {
+	unlock, err := lock(f.name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// lock acquires the advisory lock for the given history file.
+func lock(fileName string) (unlock func(), err error) {
+	lf, err := os.OpenFile(fileName+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, renamePathError(err, fileName)
+	}
+	if err := lockFile(lf); err != nil {
+		_ = lf.Close()
+		return nil, err
+	}
+	return func() {
+		_ = unlockFile(lf)
+		_ = lf.Close()
+	}, nil
+}
+
+// writeFileAtomic replaces the contents of the given file with the
+// data produced by write. The data is written to a temporary file
+// first, which is then renamed to replace the file. The lock must be
+// held.
+func writeFileAtomic(fileName string, write func(w io.Writer) error) (retErr error) {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return renamePathError(err, fileName)
+	}
+	defer func() {
+		if retErr != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if info, err := os.Stat(fileName); err == nil {
+		// Preserve the permissions of the existing file.
+		if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
+
+// renamePathError reports errors on the lock file or the temporary
+// file as errors on the history file, which is more meaningful to
+// users.
+func renamePathError(err error, fileName string) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return &os.PathError{Op: pe.Op, Path: fileName, Err: pe.Err}
+	}
+	return err
+}
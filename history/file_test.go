@@ -0,0 +1,206 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileSharing(t *testing.T) {
+	for _, format := range []Format{FormatLibedit, FormatJSONL} {
+		t.Run(format.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			fname := filepath.Join(dir, "history")
+			f1 := NewFile(fname, format)
+			f2 := NewFile(fname, format)
+
+			check := func(what string, actual []Entry, exp ...string) {
+				t.Helper()
+				if a := Texts(actual); !reflect.DeepEqual(a, exp) {
+					t.Errorf("%s: expected %q, got %q", what, exp, a)
+				}
+			}
+			checkAppend := func(f *File, text string, expReload bool, exp ...string) {
+				t.Helper()
+				others, reload, err := f.Append(Entry{Text: text})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if reload != expReload {
+					t.Errorf("append %q: expected reload %v, got %v", text, expReload, reload)
+				}
+				check(fmt.Sprintf("append %q", text), others, exp...)
+			}
+
+			// Each session sees the entries appended by the other.
+			checkAppend(f1, "a", false)
+			checkAppend(f2, "b", false, "a")
+			checkAppend(f2, "c", false)
+			checkAppend(f1, "d", false, "b", "c")
+
+			h, err := NewFile(fname, format).Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			check("load", h, "a", "b", "c", "d")
+			if f1.Len() != 4 {
+				t.Errorf("expected 4 entries, got %d", f1.Len())
+			}
+
+			// The file remains in the libedit format if requested.
+			if format == FormatLibedit {
+				h, err := LoadHistory(fname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(h, []string{"a", "b", "c", "d"}) {
+					t.Errorf("unexpected history: %q", h)
+				}
+			}
+
+			// Compaction preserves the entries appended concurrently.
+			checkAppend(f2, "e", false, "d")
+			h, err = f1.Compact([]Entry{{Text: "c"}, {Text: "d"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			check("compact", h, "c", "d", "e")
+
+			// The other session notices the file was replaced.
+			checkAppend(f2, "f", true, "c", "d", "e")
+			checkAppend(f1, "g", false, "f")
+
+			// No temporary file remains.
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name())
+			}
+			if exp := []string{"history", "history.lock"}; !reflect.DeepEqual(names, exp) {
+				t.Errorf("expected files %q, got %q", exp, names)
+			}
+		})
+	}
+}
+
+func TestFileConcurrentAppend(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	const numSessions, numEntries = 5, 20
+	var wg sync.WaitGroup
+	for i := 0; i < numSessions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f := NewFile(fname, FormatJSONL)
+			for j := 0; j < numEntries; j++ {
+				if _, _, err := f.Append(Entry{Text: fmt.Sprintf("%d-%d", i, j)}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	h, err := LoadEntries(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h) != numSessions*numEntries {
+		t.Errorf("expected %d entries, got %d", numSessions*numEntries, len(h))
+	}
+}
+
+func TestFileEphemeral(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	f := NewFile(fname, FormatJSONL)
+	if _, _, err := f.Append(Entry{Text: "a"}, Entry{Text: "b", Ephemeral: true}); err != nil {
+		t.Fatal(err)
+	}
+	if f.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", f.Len())
+	}
+
+	// Compaction returns the ephemeral entries but does not save them.
+	h, err := f.Compact([]Entry{{Text: "a"}, {Text: "b", Ephemeral: true}, {Text: "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []Entry{{Text: "a"}, {Text: "b", Ephemeral: true}, {Text: "c"}}; !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected %+v, got %+v", exp, h)
+	}
+	if f.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", f.Len())
+	}
+	h, err = LoadEntries(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"a", "c"}; !reflect.DeepEqual(Texts(h), exp) {
+		t.Errorf("expected %q, got %q", exp, Texts(h))
+	}
+}
+
+func TestFileCompactFormat(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, _, err := NewFile(fname, FormatJSONL).Append(Entry{Text: "a", Time: ts}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file keeps the JSON lines format, and thus the metadata, when
+	// it is compacted by a session configured with another format.
+	f := NewFile(fname, FormatLibedit)
+	if _, err := f.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Compact([]Entry{{Text: "a", Time: ts}, {Text: "b", Time: ts}}); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	h, format, err := loadEntriesAndFormat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != FormatJSONL {
+		t.Errorf("expected format %v, got %v", FormatJSONL, format)
+	}
+	if exp := []string{"a", "b"}; !reflect.DeepEqual(Texts(h), exp) || !h[1].Time.Equal(ts) {
+		t.Errorf("expected %q with timestamps, got %+v", exp, h)
+	}
+}
+
+func TestSaveEntriesAtomic(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "history")
+	if err := SaveHistory([]string{"a", "b"}, fname); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed write leaves the previous contents in place.
+	err := SaveEntries([]Entry{{Text: "c"}}, fname, Format(42))
+	if err == nil || err.Error() != "unsupported history format: Format(42)" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	h, err := LoadHistory(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h, []string{"a", "b"}) {
+		t.Errorf("unexpected history: %q", h)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Errorf("expected history and lock files only, got %v", files)
+	}
+}
@@ -5,23 +5,17 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
-	"os"
 )
 
 const cookie = "_HiStOrY_V2_"
 
 // LoadHistory loadsa a history from a file loaded from the specified
-// path. The file must be in the same format as used by libedit.
+// path. The file must be in the same format as used by libedit, or
+// in the JSON lines format (see LoadEntries). In the latter case,
+// only the text of the entries is returned.
 func LoadHistory(fileName string) ([]string, error) {
-	f, err := os.Open(fileName)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	defer func() { _ = f.Close() }()
-	return loadHistoryFromFile(f)
+	h, err := LoadEntries(fileName)
+	return Texts(h), err
 }
 
 func loadHistoryFromFile(f io.Reader) ([]string, error) {
@@ -40,6 +34,12 @@ func loadHistoryFromFile(f io.Reader) ([]string, error) {
 		return nil, nil
 	}
 	// Read the remainder of the file.
+	return decodeLines(f)
+}
+
+// decodeLines decodes history entries in the libedit format, after
+// the cookie.
+func decodeLines(f io.Reader) ([]string, error) {
 	contents, err := ioutil.ReadAll(f)
 	if err != nil {
 		return nil, err
@@ -47,6 +47,10 @@ func loadHistoryFromFile(f io.Reader) ([]string, error) {
 	if len(contents) > 0 && contents[len(contents)-1] == '\n' {
 		contents = contents[:len(contents)-1]
 	}
+	if len(contents) == 0 {
+		// No entries.
+		return nil, nil
+	}
 	lines := bytes.Split(contents, []byte("\n"))
 	hist := make([]string, 0, len(lines))
 	for _, line := range lines {
@@ -83,19 +87,9 @@ func loadHistoryFromFile(f io.Reader) ([]string, error) {
 
 // SaveHistory saves a history to the specified file.
 // The file will be written in the same format as used by libedit.
-func SaveHistory(h []string, fileName string) (retErr error) {
-	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		closeErr := f.Close()
-		if retErr == nil {
-			retErr = closeErr
-		}
-	}()
-
-	return saveHistoryToFile(h, f)
+// The previous contents of the file are replaced atomically.
+func SaveHistory(h []string, fileName string) error {
+	return SaveEntries(FromTexts(h), fileName, FormatLibedit)
 }
 
 func saveHistoryToFile(h []string, f io.Writer) error {
@@ -104,6 +98,14 @@ func saveHistoryToFile(h []string, f io.Writer) error {
 	if err != nil {
 		return err
 	}
+	if err := encodeLines(w, h); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// encodeLines encodes history entries in the libedit format.
+func encodeLines(w io.Writer, h []string) error {
 	for _, entry := range h {
 		var buf bytes.Buffer
 		for c := 0; c < len(entry); c++ {
@@ -122,5 +124,5 @@ func saveHistoryToFile(h []string, f io.Writer) error {
 			return err
 		}
 	}
-	return w.Flush()
+	return nil
 }
@@ -0,0 +1,358 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShellFormat is the format of the history file of another program,
+// for use with Import and Export.
+type ShellFormat int
+
+const (
+	// ShellBash is the format of bash history files. Timestamps are
+	// recognized in the "#1700000000" lines written when
+	// HISTTIMEFORMAT is set. When timestamps are present, the lines
+	// between two timestamps form one entry, so that multi-line
+	// entries are preserved.
+	ShellBash ShellFormat = iota
+	// ShellZsh is the format of zsh history files, including the
+	// extended history format (": 1700000000:0;cmd") where a
+	// backslash at the end of a line continues the entry on the next
+	// line.
+	ShellZsh
+	// ShellFish is the format of fish history files.
+	ShellFish
+	// ShellReadline is the format of plain readline history files,
+	// with one entry per line, as written e.g. by psql. Files in the
+	// libedit format are also recognized.
+	ShellReadline
+)
+
+// String implements the fmt.Stringer interface.
+func (f ShellFormat) String() string {
+	switch f {
+	case ShellBash:
+		return "bash"
+	case ShellZsh:
+		return "zsh"
+	case ShellFish:
+		return "fish"
+	case ShellReadline:
+		return "readline"
+	default:
+		return fmt.Sprintf("ShellFormat(%d)", int(f))
+	}
+}
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// Filter, if set, selects the entries to import.
+	Filter func(Entry) bool
+	// Dedup, if true, only imports the most recent of the entries with
+	// the same text.
+	Dedup bool
+}
+
+// MatchRegexp returns a filter for ImportOptions that selects the
+// entries matching re.
+func MatchRegexp(re *regexp.Regexp) func(Entry) bool {
+	return func(e Entry) bool { return re.MatchString(e.Text) }
+}
+
+// ImportFile imports the entries from the history file of another
+// program at the specified path. A file that does not exist yet is
+// considered to be empty.
+func ImportFile(fileName string, format ShellFormat, opts ImportOptions) ([]Entry, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return Import(f, format, opts)
+}
+
+// Import imports the entries from the history file of another
+// program, in the given format, oldest first.
+func Import(r io.Reader, format ShellFormat, opts ImportOptions) ([]Entry, error) {
+	var h []Entry
+	var err error
+	switch format {
+	case ShellBash:
+		h, err = importBash(r)
+	case ShellZsh:
+		h, err = importZsh(r)
+	case ShellFish:
+		h, err = importFish(r)
+	case ShellReadline:
+		h, err = importReadline(r)
+	default:
+		return nil, fmt.Errorf("unsupported history format: %v", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.Filter != nil {
+		filtered := h[:0]
+		for _, e := range h {
+			if opts.Filter(e) {
+				filtered = append(filtered, e)
+			}
+		}
+		h = filtered
+	}
+	if opts.Dedup {
+		h = Dedup(h, false /* ignoreSpace */)
+	}
+	return h, nil
+}
+
+// readLines returns the lines of a history file.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimSuffix(sc.Text(), "\r"))
+	}
+	return lines, sc.Err()
+}
+
+// parseUnixTime parses a timestamp in seconds since the epoch.
+func parseUnixTime(s string) (time.Time, bool) {
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || ts < 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(ts, 0), true
+}
+
+func importBash(r io.Reader) ([]Entry, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	var h []Entry
+	timestamped := false
+	for _, line := range lines {
+		if len(line) > 1 && line[0] == '#' {
+			if t, ok := parseUnixTime(line[1:]); ok {
+				h = append(h, Entry{Time: t})
+				timestamped = true
+				continue
+			}
+		}
+		if timestamped && len(h) > 0 {
+			// Lines after a timestamp belong to the same entry.
+			if e := &h[len(h)-1]; e.Text == "" {
+				e.Text = line
+			} else {
+				e.Text += "\n" + line
+			}
+			continue
+		}
+		h = append(h, Entry{Text: line})
+	}
+	return removeEmpty(h), nil
+}
+
+// removeEmpty removes the entries without text.
+func removeEmpty(h []Entry) []Entry {
+	res := h[:0]
+	for _, e := range h {
+		if e.Text != "" {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// zshMeta is the character used by zsh to escape the special bytes
+// in its history file.
+const zshMeta = 0x83
+
+// zshUnmetafy decodes the special bytes in a line of a zsh history
+// file.
+func zshUnmetafy(s string) string {
+	if strings.IndexByte(s, zshMeta) < 0 {
+		return s
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == zshMeta && i+1 < len(s) {
+			i++
+			buf.WriteByte(s[i] ^ 32)
+			continue
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// zshMetafy encodes the special bytes in an entry for a zsh history
+// file.
+func zshMetafy(s string) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == 0 || (c >= zshMeta && c <= 0xa2) {
+			buf.WriteByte(zshMeta)
+			buf.WriteByte(c ^ 32)
+			continue
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+func importZsh(r io.Reader) ([]Entry, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	var h []Entry
+	continued := false
+	for _, line := range lines {
+		line = zshUnmetafy(line)
+		// A backslash at the end of the line continues the entry.
+		next := strings.HasSuffix(line, "\\")
+		if next {
+			line = line[:len(line)-1]
+		}
+		if continued {
+			h[len(h)-1].Text += "\n" + line
+			continued = next
+			continue
+		}
+		continued = next
+		var e Entry
+		if rest, ok := strings.CutPrefix(line, ": "); ok {
+			// Extended history: ": <start>:<duration>;<command>".
+			meta, text, found := strings.Cut(rest, ";")
+			start, elapsed, _ := strings.Cut(meta, ":")
+			if t, ok := parseUnixTime(start); found && ok {
+				e.Time = t
+				if d, err := strconv.Atoi(elapsed); err == nil {
+					e.Duration = time.Duration(d) * time.Second
+				}
+				line = text
+			}
+		}
+		e.Text = line
+		h = append(h, e)
+	}
+	return removeEmpty(h), nil
+}
+
+// fishUnescape decodes the command of an entry in a fish history file.
+func fishUnescape(s string) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				buf.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				buf.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// fishEscape encodes the command of an entry for a fish history file.
+func fishEscape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(s)
+}
+
+func importFish(r io.Reader) ([]Entry, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	var h []Entry
+	for _, line := range lines {
+		if cmd, ok := strings.CutPrefix(line, "- cmd: "); ok {
+			h = append(h, Entry{Text: fishUnescape(cmd)})
+			continue
+		}
+		if when, ok := strings.CutPrefix(line, "  when: "); ok && len(h) > 0 {
+			if t, ok := parseUnixTime(when); ok {
+				h[len(h)-1].Time = t
+			}
+		}
+		// Other lines, e.g. the paths, are ignored.
+	}
+	return removeEmpty(h), nil
+}
+
+func importReadline(r io.Reader) ([]Entry, error) {
+	br := bufio.NewReader(r)
+	if start, _ := br.Peek(len(cookie) + 1); bytes.Equal(start, []byte(cookie+"\n")) {
+		// libedit format.
+		h, err := loadHistoryFromFile(br)
+		return FromTexts(h), err
+	}
+	lines, err := readLines(br)
+	if err != nil {
+		return nil, err
+	}
+	return removeEmpty(FromTexts(lines)), nil
+}
+
+// Export writes the entries in the format of the history file of
+// another program. Ephemeral entries are skipped. The metadata not supported by the format is
+// omitted. In the bash format, the entries without a timestamp that
+// follow an entry with a timestamp are given the same timestamp, and
+// multi-line entries are only preserved if they have a timestamp. In
+// the readline format, multi-line entries are joined with spaces.
+func Export(w io.Writer, h []Entry, format ShellFormat) error {
+	bw := bufio.NewWriter(w)
+	var lastTime time.Time
+	for _, e := range persistent(h) {
+		switch format {
+		case ShellBash:
+			if !e.Time.IsZero() {
+				lastTime = e.Time
+			}
+			// Once timestamps are used, every entry needs one, otherwise
+			// it would be merged with the previous entry on import.
+			if !lastTime.IsZero() {
+				fmt.Fprintf(bw, "#%d\n", lastTime.Unix())
+			}
+			bw.WriteString(e.Text)
+		case ShellZsh:
+			text := zshMetafy(strings.ReplaceAll(e.Text, "\n", "\\\n"))
+			if e.Time.IsZero() {
+				bw.WriteString(text)
+			} else {
+				fmt.Fprintf(bw, ": %d:%d;%s", e.Time.Unix(), int64(e.Duration/time.Second), text)
+			}
+		case ShellFish:
+			fmt.Fprintf(bw, "- cmd: %s", fishEscape(e.Text))
+			if !e.Time.IsZero() {
+				fmt.Fprintf(bw, "\n  when: %d", e.Time.Unix())
+			}
+		case ShellReadline:
+			bw.WriteString(strings.ReplaceAll(e.Text, "\n", " "))
+		default:
+			return fmt.Errorf("unsupported history format: %v", format)
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,112 @@
+package history
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImport(t *testing.T) {
+	t1, t2 := time.Unix(1700000000, 0), time.Unix(1700000060, 0)
+	testCases := []struct {
+		format ShellFormat
+		input  string
+		opts   ImportOptions
+		exp    []Entry
+	}{
+		// bash.
+		{ShellBash, "", ImportOptions{}, nil},
+		{ShellBash, "ls\n#comment\n\ncd /tmp\n", ImportOptions{},
+			[]Entry{{Text: "ls"}, {Text: "#comment"}, {Text: "cd /tmp"}}},
+		{ShellBash, "#1700000000\nls\n#1700000060\nfor i in 1 2; do\necho $i\ndone\n", ImportOptions{},
+			[]Entry{{Text: "ls", Time: t1}, {Text: "for i in 1 2; do\necho $i\ndone", Time: t2}}},
+
+		// zsh.
+		{ShellZsh, "ls\ncd /tmp\n", ImportOptions{},
+			[]Entry{{Text: "ls"}, {Text: "cd /tmp"}}},
+		{ShellZsh, ": 1700000000:3;make\n: 1700000060:0;for i in 1 2; do\\\necho $i\\\ndone\n", ImportOptions{},
+			[]Entry{{Text: "make", Time: t1, Duration: 3 * time.Second}, {Text: "for i in 1 2; do\necho $i\ndone", Time: t2}}},
+		{ShellZsh, ": 1700000000:0;echo \x83\xa3\x83\xa9\n", ImportOptions{},
+			[]Entry{{Text: "echo \x83\x89", Time: t1}}},
+		{ShellZsh, ": not extended\n", ImportOptions{},
+			[]Entry{{Text: ": not extended"}}},
+
+		// fish.
+		{ShellFish, "- cmd: ls\n  when: 1700000000\n- cmd: echo a\\nb \\\\n\n  when: 1700000060\n  paths:\n    - /tmp\n", ImportOptions{},
+			[]Entry{{Text: "ls", Time: t1}, {Text: "echo a\nb \\n", Time: t2}}},
+
+		// readline.
+		{ShellReadline, "select 1;\n\nselect 2;\n", ImportOptions{},
+			[]Entry{{Text: "select 1;"}, {Text: "select 2;"}}},
+		{ShellReadline, "_HiStOrY_V2_\nselect\\0401;\n", ImportOptions{},
+			[]Entry{{Text: "select 1;"}}},
+
+		// Filtering and deduplication.
+		{ShellReadline, "select 1;\n\\d\nselect 2;\nselect 1;\n", ImportOptions{Filter: MatchRegexp(regexp.MustCompile(`^select`))},
+			[]Entry{{Text: "select 1;"}, {Text: "select 2;"}, {Text: "select 1;"}}},
+		{ShellReadline, "select 1;\n\\d\nselect 2;\nselect 1;\n", ImportOptions{Dedup: true},
+			[]Entry{{Text: "\\d"}, {Text: "select 2;"}, {Text: "select 1;"}}},
+		{ShellBash, "#1700000000\nls\n#1700000060\nls\n", ImportOptions{Dedup: true},
+			[]Entry{{Text: "ls", Time: t2}}},
+	}
+
+	for _, tc := range testCases {
+		h, err := Import(strings.NewReader(tc.input), tc.format, tc.opts)
+		if err != nil {
+			t.Errorf("%v %q: expected no error, got: %v", tc.format, tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(tc.exp, h) {
+			t.Errorf("%v %q: expected:\n%+v\ngot:\n%+v", tc.format, tc.input, tc.exp, h)
+		}
+	}
+
+	if _, err := Import(strings.NewReader(""), ShellFormat(42), ImportOptions{}); err == nil ||
+		err.Error() != "unsupported history format: ShellFormat(42)" {
+		t.Errorf("expected unsupported format error, got %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	t1 := time.Unix(1700000000, 0)
+	entries := []Entry{
+		{Text: "make", Time: t1, Duration: 3 * time.Second},
+		{Text: "for i in 1 2; do\necho \\$i\ndone", Time: t1},
+		{Text: "ls \x89"},
+	}
+	testCases := []struct {
+		format ShellFormat
+		exp    string
+	}{
+		{ShellBash, "#1700000000\nmake\n#1700000000\nfor i in 1 2; do\necho \\$i\ndone\n#1700000000\nls \x89\n"},
+		{ShellZsh, ": 1700000000:3;make\n: 1700000000:0;for i in 1 2; do\\\necho \\$i\\\ndone\nls \x83\xa9\n"},
+		{ShellFish, "- cmd: make\n  when: 1700000000\n- cmd: for i in 1 2; do\\necho \\\\$i\\ndone\n  when: 1700000000\n- cmd: ls \x89\n"},
+		{ShellReadline, "make\nfor i in 1 2; do echo \\$i done\nls \x89\n"},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		if err := Export(&buf, entries, tc.format); err != nil {
+			t.Errorf("%v: expected no error, got: %v", tc.format, err)
+			continue
+		}
+		if buf.String() != tc.exp {
+			t.Errorf("%v: expected:\n%q\ngot:\n%q", tc.format, tc.exp, buf.String())
+		}
+		if tc.format == ShellReadline {
+			continue
+		}
+		// The other formats round-trip the text.
+		h, err := Import(&buf, tc.format, ImportOptions{})
+		if err != nil {
+			t.Errorf("%v: expected no error, got: %v", tc.format, err)
+			continue
+		}
+		if !reflect.DeepEqual(Texts(entries), Texts(h)) {
+			t.Errorf("%v: expected:\n%q\ngot:\n%q", tc.format, Texts(entries), Texts(h))
+		}
+	}
+}
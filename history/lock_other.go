@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+// +build !unix,!windows
+
+package history
+
+import "os"
+
+// There is no file locking on the other platforms, e.g. js/wasm or
+// plan9: concurrent sessions may lose some history entries.
+
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
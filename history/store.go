@@ -0,0 +1,308 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// Direction is the direction of a search in a Store.
+type Direction int
+
+const (
+	// Backward searches towards the older entries.
+	Backward Direction = iota
+	// Forward searches towards the more recent entries.
+	Forward
+)
+
+// Store provides access to the entries of a history, oldest first.
+// It makes it possible to navigate and search a history without
+// loading all its entries in memory.
+type Store interface {
+	// Len returns the number of entries.
+	Len() int
+	// At returns the entry at position i, counting from 0.
+	At(i int) (Entry, error)
+	// Append adds entries at the end of the history.
+	Append(entries ...Entry) error
+	// Search returns the position of the first entry whose text
+	// matches re, starting at position from and moving in the given
+	// direction, or -1 if there is no such entry.
+	Search(re *regexp.Regexp, from int, dir Direction) (int, error)
+	// Close releases the resources used by the store.
+	Close() error
+}
+
+// searchStore implements Store.Search using Store.At.
+func searchStore(s Store, re *regexp.Regexp, from int, dir Direction) (int, error) {
+	step := 1
+	if dir == Backward {
+		step = -1
+	}
+	for i := from; i >= 0 && i < s.Len(); i += step {
+		e, err := s.At(i)
+		if err != nil {
+			return -1, err
+		}
+		if re.MatchString(e.Text) {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// errOutOfRange is returned by At for an invalid position.
+func errOutOfRange(i, n int) error {
+	return fmt.Errorf("history entry %d out of range [0:%d]", i, n)
+}
+
+// MemoryStore is a Store that keeps all the entries in memory.
+type MemoryStore struct {
+	entries []Entry
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns a MemoryStore initialized with the given
+// entries. The slice is used as-is.
+func NewMemoryStore(h []Entry) *MemoryStore {
+	return &MemoryStore{entries: h}
+}
+
+// Entries returns all the entries. The slice must not be modified.
+func (s *MemoryStore) Entries() []Entry { return s.entries }
+
+// SetEntries replaces all the entries. The slice is used as-is.
+func (s *MemoryStore) SetEntries(h []Entry) { s.entries = h }
+
+// Len implements the Store interface.
+func (s *MemoryStore) Len() int { return len(s.entries) }
+
+// At implements the Store interface.
+func (s *MemoryStore) At(i int) (Entry, error) {
+	if i < 0 || i >= len(s.entries) {
+		return Entry{}, errOutOfRange(i, len(s.entries))
+	}
+	return s.entries[i], nil
+}
+
+// Append implements the Store interface.
+func (s *MemoryStore) Append(entries ...Entry) error {
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+// Search implements the Store interface.
+func (s *MemoryStore) Search(re *regexp.Regexp, from int, dir Direction) (int, error) {
+	return searchStore(s, re, from, dir)
+}
+
+// Close implements the Store interface.
+func (s *MemoryStore) Close() error { return nil }
+
+// fileStoreCacheSize is the maximum number of entries cached by a
+// FileStore.
+const fileStoreCacheSize = 1024
+
+// FileStore is a Store backed by a history file, in the libedit or
+// JSON lines format. Only the positions of the entries in the file
+// are kept in memory, along with a cache of the entries read last;
+// the entries are read from the file on demand. This makes it
+// possible to use very large histories.
+//
+// The entries appended by other sessions, for example with File, are
+// picked up upon the next call to Append. The file must not be
+// replaced or rewritten while the store is open.
+type FileStore struct {
+	name   string
+	file   *os.File
+	format Format
+	// offsets contains the position in the file of the start of
+	// each entry, followed by the position of the end of the last
+	// entry.
+	offsets []int64
+	// cache contains the entries read last, by position.
+	cache map[int]Entry
+}
+
+var _ Store = (*FileStore)(nil)
+
+// OpenFileStore opens the history file at the specified path. The
+// file is created upon the first append if it does not exist yet,
+// using the given format. An existing file keeps its format.
+func OpenFileStore(fileName string, format Format) (*FileStore, error) {
+	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{
+		name:    fileName,
+		file:    file,
+		format:  format,
+		offsets: []int64{0},
+		cache:   make(map[int]Entry),
+	}
+	if err := s.withLock(s.index); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// withLock runs fn with the lock on the file held.
+func (s *FileStore) withLock(fn func() error) error {
+	unlock, err := lock(s.name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// index records the positions of the entries added to the file since
+// the last call. The lock must be held.
+func (s *FileStore) index() error {
+	end := s.offsets[len(s.offsets)-1]
+	if _, err := s.file.Seek(end, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(s.file)
+	if end == 0 {
+		// Detect the format of the file.
+		start, err := r.Peek(len(cookie) + 1)
+		if len(start) == 0 {
+			if err == io.EOF {
+				// Empty file: keep the configured format.
+				err = nil
+			}
+			return err
+		}
+		switch {
+		case start[0] == '{':
+			s.format = FormatJSONL
+		case bytes.Equal(start, []byte(cookie+"\n")):
+			s.format = FormatLibedit
+			_, _ = r.Discard(len(start))
+			end = int64(len(start))
+		default:
+			return fmt.Errorf("%s: unrecognized history format", s.name)
+		}
+		s.offsets[0] = end
+	}
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Long entry: skip to the end of the line.
+			for err == bufio.ErrBufferFull {
+				var more []byte
+				more, err = r.ReadSlice('\n')
+				end += int64(len(line))
+				line = more
+			}
+		}
+		if err == io.EOF {
+			// Ignore an incomplete last line, which may still be
+			// being written.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		end += int64(len(line))
+		s.offsets = append(s.offsets, end)
+	}
+}
+
+// Len implements the Store interface.
+func (s *FileStore) Len() int { return len(s.offsets) - 1 }
+
+// At implements the Store interface.
+func (s *FileStore) At(i int) (Entry, error) {
+	if i < 0 || i >= s.Len() {
+		return Entry{}, errOutOfRange(i, s.Len())
+	}
+	if e, ok := s.cache[i]; ok {
+		return e, nil
+	}
+	start, end := s.offsets[i], s.offsets[i+1]
+	buf := make([]byte, end-start)
+	if _, err := s.file.ReadAt(buf, start); err != nil {
+		return Entry{}, err
+	}
+	h, err := decodeEntries(bytes.NewReader(buf), s.format)
+	if err != nil {
+		return Entry{}, fmt.Errorf("history entry %d: %w", i+1, err)
+	}
+	var e Entry
+	if len(h) > 0 {
+		e = h[0]
+	}
+	if len(s.cache) >= fileStoreCacheSize {
+		s.cache = make(map[int]Entry)
+	}
+	s.cache[i] = e
+	return e, nil
+}
+
+// Append implements the Store interface. Ephemeral entries are not
+// written to the file, and are thus skipped.
+//
+// The entries are appended at the end of the file, after the data
+// written by the programs that do not use the lock, if any. An
+// incomplete last line is terminated first.
+func (s *FileStore) Append(entries ...Entry) error {
+	return s.withLock(func() error {
+		// Index the entries appended by other sessions first.
+		if err := s.index(); err != nil {
+			return err
+		}
+		info, err := s.file.Stat()
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if size := info.Size(); size == 0 {
+			// New file.
+			if s.format == FormatLibedit {
+				buf.WriteString(cookie + "\n")
+			}
+		} else {
+			last := make([]byte, 1)
+			if _, err := s.file.ReadAt(last, size-1); err != nil {
+				return err
+			}
+			if last[0] != '\n' {
+				buf.WriteByte('\n')
+			}
+		}
+		if err := encodeEntries(&buf, entries, s.format); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(s.name, os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(buf.Bytes())
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		return s.index()
+	})
+}
+
+// Search implements the Store interface.
+func (s *FileStore) Search(re *regexp.Regexp, from int, dir Direction) (int, error) {
+	return searchStore(s, re, from, dir)
+}
+
+// Close implements the Store interface.
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,185 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// storeTexts returns the text of all the entries in a store.
+func storeTexts(t *testing.T, s Store) []string {
+	t.Helper()
+	res := make([]string, s.Len())
+	for i := range res {
+		e, err := s.At(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res[i] = e.Text
+	}
+	return res
+}
+
+func testStore(t *testing.T, s Store) {
+	if err := s.Append(FromTexts([]string{"select 1", "multi\nline", "select 2", ""})...); err != nil {
+		t.Fatal(err)
+	}
+	if exp, h := []string{"select 1", "multi\nline", "select 2", ""}, storeTexts(t, s); !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected %q, got %q", exp, h)
+	}
+	if _, err := s.At(4); err == nil || err.Error() != "history entry 4 out of range [0:4]" {
+		t.Errorf("expected out of range error, got %v", err)
+	}
+
+	re := regexp.MustCompile(`^select`)
+	testCases := []struct {
+		from int
+		dir  Direction
+		exp  int
+	}{
+		{3, Backward, 2},
+		{1, Backward, 0},
+		{-1, Backward, -1},
+		{0, Forward, 0},
+		{1, Forward, 2},
+		{3, Forward, -1},
+		{10, Forward, -1},
+	}
+	for _, tc := range testCases {
+		i, err := s.Search(re, tc.from, tc.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != tc.exp {
+			t.Errorf("search from %d (dir %d): expected %d, got %d", tc.from, tc.dir, tc.exp, i)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(nil)
+	testStore(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	for _, format := range []Format{FormatLibedit, FormatJSONL} {
+		t.Run(format.String(), func(t *testing.T) {
+			fname := filepath.Join(t.TempDir(), "history")
+			s, err := OpenFileStore(fname, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testStore(t, s)
+
+			// The entries appended by other sessions are picked up upon
+			// the next append.
+			if _, _, err := NewFile(fname, FormatLibedit).Append(Entry{Text: "other"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Append(Entry{Text: "mine"}, Entry{Text: "secret", Ephemeral: true}); err != nil {
+				t.Fatal(err)
+			}
+			exp := []string{"select 1", "multi\nline", "select 2", "", "other", "mine"}
+			if h := storeTexts(t, s); !reflect.DeepEqual(h, exp) {
+				t.Errorf("expected %q, got %q", exp, h)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The file can be loaded as usual, and reopened.
+			h, err := LoadHistory(fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(h, exp) {
+				t.Errorf("expected %q, got %q", exp, h)
+			}
+			s, err = OpenFileStore(fname, FormatLibedit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = s.Close() }()
+			if h := storeTexts(t, s); !reflect.DeepEqual(h, exp) {
+				t.Errorf("expected %q, got %q", exp, h)
+			}
+		})
+	}
+}
+
+func TestFileStoreLarge(t *testing.T) {
+	const numEntries = 100000
+	fname := filepath.Join(t.TempDir(), "history")
+	h := make([]Entry, numEntries)
+	for i := range h {
+		h[i].Text = fmt.Sprintf("select %d", i)
+	}
+	if err := SaveEntries(h, fname, FormatJSONL); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenFileStore(fname, FormatJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	if s.Len() != numEntries {
+		t.Fatalf("expected %d entries, got %d", numEntries, s.Len())
+	}
+	i, err := s.Search(regexp.MustCompile(`^select 4200$`), numEntries-1, Backward)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != 4200 {
+		t.Errorf("expected 4200, got %d", i)
+	}
+	// The entries are only cached in part.
+	if len(s.cache) > fileStoreCacheSize {
+		t.Errorf("expected at most %d cached entries, got %d", fileStoreCacheSize, len(s.cache))
+	}
+}
+
+func TestFileStoreForeignWrites(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "history")
+	s, err := OpenFileStore(fname, FormatLibedit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.Append(Entry{Text: "select 1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another program appends to the file without taking the lock, and
+	// without a final newline.
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("other\\040program"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The data of the other program is preserved.
+	if err := s.Append(Entry{Text: "select 2"}); err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"select 1", "other program", "select 2"}
+	if h := storeTexts(t, s); !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected %q, got %q", exp, h)
+	}
+	if h, err := LoadHistory(fname); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(h, exp) {
+		t.Errorf("expected file %q, got %q", exp, h)
+	}
+}
@@ -0,0 +1,91 @@
+package bubbline
+
+import (
+	"io"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// SetInputOutput configures the editor to read its input from in and
+// display on out, instead of the process' terminal. This makes it
+// possible to serve multiple editors concurrently in the same
+// process, for example over SSH connections.
+//
+// In that case, GetLine does not install signal handlers, and the
+// key bindings that send signals to the current process (SignalQuit
+// and SignalTTYStop) are disabled. The size of the terminal must be
+// provided with Resize, and its capabilities with SetTerminalType or
+// SetColorProfile.
+func (m *Editor) SetInputOutput(in io.Reader, out io.Writer) {
+	m.input, m.output = in, out
+	m.KeyMap.SignalQuit.SetEnabled(false)
+	m.KeyMap.SignalTTYStop.SetEnabled(false)
+	m.renderer = lipgloss.NewRenderer(out, termenv.WithTTY(true))
+	m.Model.SetRenderer(m.renderer)
+}
+
+// SetTerminalType configures the colors used to display the editor
+// based on the type of the terminal (the value of the TERM
+// environment variable, e.g. "xterm-256color"). It is typically
+// used in combination with SetInputOutput.
+func (m *Editor) SetTerminalType(termType string) {
+	o := termenv.NewOutput(io.Discard, termenv.WithTTY(true), termenv.WithEnvironment(termEnviron(termType)))
+	m.SetColorProfile(o.EnvColorProfile())
+}
+
+// SetColorProfile configures the colors used to display the editor.
+func (m *Editor) SetColorProfile(p termenv.Profile) {
+	if m.renderer == nil {
+		m.renderer = lipgloss.NewRenderer(os.Stdout)
+	}
+	m.renderer.SetColorProfile(p)
+	m.Model.SetRenderer(m.renderer)
+}
+
+// Renderer returns the lipgloss renderer used to display the editor.
+// It can be used to create styles suitable for SetInputOutput.
+func (m *Editor) Renderer() *lipgloss.Renderer {
+	if m.renderer == nil {
+		return lipgloss.DefaultRenderer()
+	}
+	return m.renderer
+}
+
+// resizeMsg notifies the editor that Resize was called.
+type resizeMsg struct{}
+
+// Resize informs the editor of the size of the terminal. It can be
+// called from any goroutine, including when no GetLine call is in
+// progress. This is needed when using SetInputOutput, as the size of
+// the terminal cannot be detected automatically then.
+func (m *Editor) Resize(width, height int) {
+	m.run.mu.Lock()
+	defer m.run.mu.Unlock()
+	m.run.winSize = &tea.WindowSizeMsg{Width: width, Height: height}
+	m.run.notify(resizeMsg{})
+}
+
+// windowSize returns the size configured last with Resize.
+func (m *Editor) windowSize() (tea.WindowSizeMsg, bool) {
+	m.run.mu.Lock()
+	defer m.run.mu.Unlock()
+	if m.run.winSize == nil {
+		return tea.WindowSizeMsg{}, false
+	}
+	return *m.run.winSize, true
+}
+
+// termEnviron is a termenv.Environ that only defines TERM.
+type termEnviron string
+
+func (e termEnviron) Environ() []string { return []string{"TERM=" + string(e)} }
+
+func (e termEnviron) Getenv(key string) string {
+	if key == "TERM" {
+		return string(e)
+	}
+	return ""
+}
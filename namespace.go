@@ -0,0 +1,67 @@
+package bubbline
+
+import "github.com/knz/bubbline/history"
+
+// historyNamespace is the state of a history namespace while it is
+// not in use.
+type historyNamespace struct {
+	store history.Store
+
+	autoSave bool
+	file     string
+	hfile    *history.File
+
+	maxSize         int
+	dedup           bool
+	eraseDups       bool
+	dedupWhitespace bool
+}
+
+// HistoryNamespace returns the name of the history namespace in use.
+// The default namespace is named "".
+func (m *Editor) HistoryNamespace() string {
+	return m.histNamespace
+}
+
+// SetHistoryNamespace selects the history used by the following calls
+// to GetLine and to the history methods, for example to keep the
+// answers to confirmation prompts out of the history of the main
+// input.
+//
+// Each namespace has its own entries (or store, see
+// SetHistoryStore), history file (see SetAutoSaveHistory and
+// LoadHistory), MaxHistorySize and dedup policy (DedupHistory,
+// EraseDupHistory and DedupWhitespace). A new
+// namespace starts with an empty history and no history file, and
+// with the size limit and dedup policy of the namespace in use.
+func (m *Editor) SetHistoryNamespace(name string) {
+	if name == m.histNamespace {
+		return
+	}
+	if m.namespaces == nil {
+		m.namespaces = make(map[string]*historyNamespace)
+	}
+	m.namespaces[m.histNamespace] = &historyNamespace{
+		store:           m.HistoryStore(),
+		autoSave:        m.autoSaveHistory,
+		file:            m.histFile,
+		hfile:           m.hfile,
+		maxSize:         m.MaxHistorySize,
+		dedup:           m.DedupHistory,
+		eraseDups:       m.EraseDupHistory,
+		dedupWhitespace: m.DedupWhitespace,
+	}
+	m.histNamespace = name
+	ns, ok := m.namespaces[name]
+	if !ok {
+		// New namespace.
+		m.autoSaveHistory, m.histFile, m.hfile = false, "", nil
+		m.SetHistoryEntries(nil)
+		return
+	}
+	delete(m.namespaces, name)
+	m.autoSaveHistory, m.histFile, m.hfile = ns.autoSave, ns.file, ns.hfile
+	m.MaxHistorySize = ns.maxSize
+	m.DedupHistory, m.EraseDupHistory, m.DedupWhitespace = ns.dedup, ns.eraseDups, ns.dedupWhitespace
+	m.SetHistoryStore(ns.store)
+}
@@ -0,0 +1,55 @@
+package bubbline
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// asyncOutputMsg notifies the editor that there is output to display.
+type asyncOutputMsg struct{}
+
+var _ io.Writer = (*Editor)(nil)
+
+// Write implements the io.Writer interface. It can be used
+// concurrently from any goroutine to display output above the editor
+// without disturbing the input. The prompt and the input are redrawn
+// below the output.
+//
+// The output is displayed line by line: an incomplete last line is
+// held until it is terminated by a newline character. Output written
+// while no GetLine call is in progress is displayed at the next
+// prompt.
+func (m *Editor) Write(p []byte) (int, error) {
+	m.run.mu.Lock()
+	defer m.run.mu.Unlock()
+	m.run.outBuf = append(m.run.outBuf, p...)
+	m.run.notify(asyncOutputMsg{})
+	return len(p), nil
+}
+
+// Printf formats according to a format specifier and displays the
+// result above the editor, like Write. A newline is appended if
+// missing.
+func (m *Editor) Printf(format string, args ...interface{}) {
+	s := fmt.Sprintf(format, args...)
+	if len(s) == 0 || s[len(s)-1] != '\n' {
+		s += "\n"
+	}
+	_, _ = m.Write([]byte(s))
+}
+
+// flushOutput retrieves the complete lines written so far.
+func (m *Editor) flushOutput() tea.Cmd {
+	m.run.mu.Lock()
+	defer m.run.mu.Unlock()
+	i := bytes.LastIndexByte(m.run.outBuf, '\n')
+	if i < 0 {
+		return nil
+	}
+	text := string(m.run.outBuf[:i])
+	m.run.outBuf = append(m.run.outBuf[:0], m.run.outBuf[i+1:]...)
+	return tea.Println(text)
+}